@@ -11,6 +11,10 @@ import (
 	"projeto_drm/poc/internal/database"
 	"projeto_drm/poc/internal/handlers"
 	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/ratelimit"
+	"projeto_drm/poc/internal/scanner"
+	"projeto_drm/poc/internal/storage"
+	"projeto_drm/poc/internal/webhook"
 	"projeto_drm/poc/internal/worker"
 	"syscall"
 	"time"
@@ -24,10 +28,26 @@ func main() {
 		log.Println("Arquivo .env não encontrado ou não pôde ser carregado")
 	}
 
+	if err := auth.InitKeys(); err != nil {
+		log.Fatalf("Erro ao carregar chaves de assinatura JWT: %v", err)
+	}
+	auth.StartKeyRotation(time.Hour)
+	ratelimit.Init()
+
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Erro ao configurar armazenamento: %v", err)
+	}
+	scanner.Init()
+
 	database.InitDatabase()
+	auth.SeedAdmin()
+	auth.InitOIDC()
 
 	r := gin.Default()
-	r.POST("/auth/login", auth.LoginHandler)
+	r.GET("/.well-known/jwks.json", auth.JWKSHandler)
+	r.POST("/auth/register", auth.RegisterHandler)
+	r.POST("/auth/login", ratelimit.Middleware(ratelimit.LoginByIP, ratelimit.ByIP), auth.LoginHandler)
+	r.POST("/auth/refresh", auth.RefreshHandler)
 	r.Use(auth.Middleware())
 	handlers.RegisterRoutes(r)
 
@@ -49,6 +69,12 @@ func main() {
 
 	// Inicializar cleanup automático (limpa arquivos com mais de 24 horas)
 	cleanup.StartCacheCleanup(time.Hour, 24*time.Hour)
+	// Descarta partes de uploads resumíveis abandonados
+	cleanup.StartUploadCleanup(time.Hour)
+	// Expurga os assets que passaram do prazo na lixeira
+	cleanup.StartTrashCleanup(time.Hour, handlers.PurgeExpiredTrash)
+	// Envia os eventos aos webhooks cadastrados
+	webhook.StartDispatcher(5 * time.Second)
 
 	s := &http.Server{
 		Addr:           ":8080",
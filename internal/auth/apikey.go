@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+	"time"
+)
+
+const (
+	ScopeRead     = "assets:read"
+	ScopeDownload = "assets:download"
+	ScopeUpload   = "assets:upload"
+
+	apiKeyHeader = "X-API-Key"
+	apiKeyPrefix = "drm"
+
+	// Evita uma escrita no banco a cada requisição feita com a mesma chave
+	apiKeyTouchInterval = time.Minute
+)
+
+var errInvalidAPIKey = errors.New("API key inválida")
+
+// ValidScope indica se o escopo pode ser atribuído a uma API key
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeDownload, ScopeUpload:
+		return true
+	}
+	return false
+}
+
+// GenerateAPIKey cria uma chave no formato drm_<prefixo>_<segredo> e
+// retorna a chave completa, o prefixo identificador e o hash para persistência
+func GenerateAPIKey() (key, prefix, hash string, err error) {
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err = rand.Read(id); err != nil {
+		return
+	}
+	if _, err = rand.Read(secret); err != nil {
+		return
+	}
+
+	prefix = fmt.Sprintf("%s_%s", apiKeyPrefix, hex.EncodeToString(id))
+	key = fmt.Sprintf("%s_%s", prefix, base64.RawURLEncoding.EncodeToString(secret))
+	hash = hashAPIKey(key)
+	return
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIKey valida a chave e retorna o usuário dono dela com os
+// escopos da chave
+func authenticateAPIKey(key string) (UserInfo, error) {
+	// O segredo em base64url também pode conter "_"
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return UserInfo{}, errInvalidAPIKey
+	}
+	prefix := parts[0] + "_" + parts[1]
+
+	var apiKey models.APIKey
+	if err := database.DB.Where("prefix = ?", prefix).First(&apiKey).Error; err != nil {
+		return UserInfo{}, errInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(apiKey.KeyHash), []byte(hashAPIKey(key))) != 1 {
+		return UserInfo{}, errInvalidAPIKey
+	}
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(time.Now()) {
+		return UserInfo{}, errors.New("API key expirada")
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, apiKey.UserID).Error; err != nil {
+		return UserInfo{}, errInvalidAPIKey
+	}
+
+	if apiKey.LastUsedAt == nil || time.Since(*apiKey.LastUsedAt) > apiKeyTouchInterval {
+		now := time.Now()
+		if err := database.DB.Model(&apiKey).UpdateColumn("last_used_at", now).Error; err != nil {
+			log.Printf("Erro ao atualizar último uso da API key %s: %v", apiKey.Prefix, err)
+		}
+	}
+
+	scopes := strings.Split(apiKey.Scopes, ",")
+	if apiKey.Scopes == "" {
+		scopes = []string{}
+	}
+
+	return UserInfo{
+		ID:       fmt.Sprintf("%d", user.ID),
+		Email:    user.Email,
+		Role:     user.Role,
+		TenantID: user.TenantID,
+		Scopes:   scopes,
+		APIKeyID: apiKey.ID,
+	}, nil
+}
+
+// RequireScope restringe a rota às API keys que possuem o escopo informado.
+// Usuários autenticados por token não têm restrição de escopo.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRaw, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+			c.Abort()
+			return
+		}
+		user := userRaw.(UserInfo)
+
+		if user.Scopes == nil {
+			c.Next()
+			return
+		}
+
+		for _, s := range user.Scopes {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		Forbidden(c)
+	}
+}
+
+// RejectAPIKeys bloqueia rotas administrativas para chamadas feitas com API key
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRaw, exists := c.Get("user")
+		if exists && userRaw.(UserInfo).APIKeyID != 0 {
+			Forbidden(c)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"fmt"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+	"testing"
+)
+
+func createAPIKeyOwner(t *testing.T) models.User {
+	t.Helper()
+	setupTestDB(t)
+
+	user := models.User{Email: "apikey@example.com", Role: models.RoleUploader, TenantID: 1}
+	if err := database.DB.Create(&user).Error; err != nil {
+		t.Fatalf("criar usuário: %v", err)
+	}
+	return user
+}
+
+func TestAuthenticateGeneratedAPIKeys(t *testing.T) {
+	user := createAPIKeyOwner(t)
+
+	// Cerca de metade das chaves tem "_" no segredo; com 200 chaves a chance
+	// de nenhuma ter é desprezível
+	withUnderscore := 0
+	for i := 0; i < 200; i++ {
+		key, prefix, hash, err := GenerateAPIKey()
+		if err != nil {
+			t.Fatalf("gerar chave: %v", err)
+		}
+		if strings.Contains(strings.TrimPrefix(key, prefix+"_"), "_") {
+			withUnderscore++
+		}
+
+		apiKey := models.APIKey{UserID: user.ID, Prefix: prefix, KeyHash: hash, Scopes: ScopeRead}
+		if err := database.DB.Create(&apiKey).Error; err != nil {
+			t.Fatalf("salvar chave: %v", err)
+		}
+
+		info, err := authenticateAPIKey(key)
+		if err != nil {
+			t.Fatalf("chave %q rejeitada: %v", key, err)
+		}
+		if info.APIKeyID != apiKey.ID || info.ID != fmt.Sprint(user.ID) {
+			t.Fatalf("chave %q autenticou %+v", key, info)
+		}
+		if len(info.Scopes) != 1 || info.Scopes[0] != ScopeRead {
+			t.Fatalf("escopos = %v", info.Scopes)
+		}
+	}
+	if withUnderscore == 0 {
+		t.Fatal("nenhuma chave gerada com \"_\" no segredo")
+	}
+}
+
+func TestAuthenticateAPIKeyRejectsInvalidKeys(t *testing.T) {
+	user := createAPIKeyOwner(t)
+
+	key, prefix, hash, err := GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("gerar chave: %v", err)
+	}
+	if err := database.DB.Create(&models.APIKey{UserID: user.ID, Prefix: prefix, KeyHash: hash}).Error; err != nil {
+		t.Fatalf("salvar chave: %v", err)
+	}
+
+	for _, invalid := range []string{
+		"",
+		prefix,
+		"xyz" + strings.TrimPrefix(key, apiKeyPrefix),
+		key + "x",
+		prefix + "_",
+	} {
+		if _, err := authenticateAPIKey(invalid); err == nil {
+			t.Errorf("chave %q aceita", invalid)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKSHandler publica as chaves públicas de verificação para outros serviços
+func JWKSHandler(c *gin.Context) {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range keys.all() {
+		set.Keys = append(set.Keys, publicJWK(key))
+	}
+
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, set)
+}
+
+func publicJWK(key *signingKey) JWK {
+	jwk := JWK{Kid: key.ID, Use: "sig", Alg: key.Method.Alg()}
+
+	switch pub := key.Private.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64URL(pub.N.Bytes())
+		jwk.E = encodeBase64URL(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeBase64URL(pub.X.FillBytes(make([]byte, size)))
+		jwk.Y = encodeBase64URL(pub.Y.FillBytes(make([]byte, size)))
+	}
+
+	return jwk
+}
+
+func encodeBase64URL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// publicKey converte uma JWK pública (RSA ou EC) na chave correspondente
+func (jwk JWK) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBase64URL(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBase64URL(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("curva não suportada: %s", jwk.Crv)
+		}
+		x, err := decodeBase64URL(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBase64URL(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("tipo de chave não suportado: %s", jwk.Kty)
+	}
+}
+
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
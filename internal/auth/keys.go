@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"log"
+	"os"
+	"path/filepath"
+	"projeto_drm/poc/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// signingKey é uma chave privada usada para assinar tokens, identificada pelo kid
+type signingKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	Private   crypto.Signer
+	CreatedAt time.Time
+}
+
+type keyStore struct {
+	mu   sync.RWMutex
+	dir  string
+	alg  string
+	keys map[string]*signingKey
+}
+
+var keys = &keyStore{keys: map[string]*signingKey{}}
+
+var (
+	keyRotationInterval = 30 * 24 * time.Hour
+	errNoSigningKey     = errors.New("nenhuma chave de assinatura configurada")
+)
+
+// InitKeys lê a configuração de tokens do ambiente e carrega as chaves de
+// assinatura do diretório JWT_KEYS_DIR. Se o diretório estiver vazio a
+// inicialização falha, a menos que JWT_GENERATE_KEYS=true, caso em que uma
+// chave nova é gerada e persistida.
+func InitKeys() error {
+	accessTokenTTL = durationFromEnv("ACCESS_TOKEN_TTL", accessTokenTTL)
+	refreshTokenTTL = durationFromEnv("REFRESH_TOKEN_TTL", refreshTokenTTL)
+	keyRotationInterval = durationFromEnv("JWT_KEY_ROTATION", keyRotationInterval)
+	mfaRequiredRoles = parseRoles(os.Getenv("MFA_REQUIRED_ROLES"), []string{models.RoleSuperAdmin, models.RoleAdmin, models.RoleUploader})
+
+	keys.dir = os.Getenv("JWT_KEYS_DIR")
+	if keys.dir == "" {
+		keys.dir = filepath.Join("data", "keys")
+	}
+	keys.alg = strings.ToUpper(os.Getenv("JWT_SIGNING_ALG"))
+	if keys.alg == "" {
+		keys.alg = "ES256"
+	}
+	if keys.alg != "ES256" && keys.alg != "RS256" {
+		return fmt.Errorf("JWT_SIGNING_ALG inválido: %s", keys.alg)
+	}
+
+	if err := keys.load(); err != nil {
+		return err
+	}
+
+	if keys.count() == 0 {
+		if os.Getenv("JWT_GENERATE_KEYS") != "true" {
+			return fmt.Errorf("%w em %s (defina JWT_GENERATE_KEYS=true para gerar uma)", errNoSigningKey, keys.dir)
+		}
+		if _, err := keys.generate(); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("%d chave(s) de assinatura carregada(s); kid ativo: %s", keys.count(), keys.current().ID)
+	return nil
+}
+
+// StartKeyRotation gera uma nova chave quando a chave ativa fica mais velha
+// que JWT_KEY_ROTATION. Chaves antigas continuam válidas para verificação até
+// que nenhum token assinado por elas possa estar vigente.
+func StartKeyRotation(checkInterval time.Duration) {
+	ticker := time.NewTicker(checkInterval)
+	go func() {
+		for range ticker.C {
+			if err := keys.rotateIfNeeded(); err != nil {
+				log.Printf("Erro ao rotacionar chave de assinatura: %v", err)
+			}
+		}
+	}()
+}
+
+func (ks *keyStore) load() error {
+	if err := os.MkdirAll(ks.dir, 0700); err != nil {
+		return fmt.Errorf("erro ao criar diretório de chaves: %v", err)
+	}
+
+	entries, err := os.ReadDir(ks.dir)
+	if err != nil {
+		return fmt.Errorf("erro ao ler diretório de chaves: %v", err)
+	}
+
+	loaded := map[string]*signingKey{}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".pem" {
+			continue
+		}
+
+		path := filepath.Join(ks.dir, entry.Name())
+		key, err := readPrivateKey(path)
+		if err != nil {
+			return fmt.Errorf("erro ao ler chave %s: %v", entry.Name(), err)
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		key.ID = strings.TrimSuffix(entry.Name(), ".pem")
+		key.CreatedAt = info.ModTime()
+		loaded[key.ID] = key
+	}
+
+	ks.mu.Lock()
+	ks.keys = loaded
+	ks.mu.Unlock()
+	return nil
+}
+
+func (ks *keyStore) generate() (*signingKey, error) {
+	var private crypto.Signer
+	var method jwt.SigningMethod
+	var err error
+
+	switch ks.alg {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+		method = jwt.SigningMethodRS256
+	default:
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		method = jwt.SigningMethodES256
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erro ao gerar chave: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	key := &signingKey{
+		ID:        fmt.Sprintf("%s-%d-%s", strings.ToLower(ks.alg), now.Unix(), uuid.New().String()[:8]),
+		Method:    method,
+		Private:   private,
+		CreatedAt: now,
+	}
+
+	path := filepath.Join(ks.dir, key.ID+".pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("erro ao salvar chave: %v", err)
+	}
+
+	ks.mu.Lock()
+	ks.keys[key.ID] = key
+	ks.mu.Unlock()
+
+	log.Printf("Nova chave de assinatura gerada: %s", key.ID)
+	return key, nil
+}
+
+func (ks *keyStore) rotateIfNeeded() error {
+	// Recarrega do disco para enxergar chaves criadas por outras instâncias
+	if err := ks.load(); err != nil {
+		return err
+	}
+
+	current := ks.current()
+	if current == nil || time.Since(current.CreatedAt) >= keyRotationInterval {
+		if _, err := ks.generate(); err != nil {
+			return err
+		}
+	}
+
+	// Uma chave substituída ainda verifica tokens até o maior TTL expirar
+	retention := keyRotationInterval + refreshTokenTTL
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	for id, key := range ks.keys {
+		if time.Since(key.CreatedAt) > retention {
+			if err := os.Remove(filepath.Join(ks.dir, id+".pem")); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			delete(ks.keys, id)
+			log.Printf("Chave de assinatura removida: %s", id)
+		}
+	}
+
+	return nil
+}
+
+// current retorna a chave mais recente, usada para assinar novos tokens
+func (ks *keyStore) current() *signingKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	var newest *signingKey
+	for _, key := range ks.keys {
+		if newest == nil || key.CreatedAt.After(newest.CreatedAt) {
+			newest = key
+		}
+	}
+	return newest
+}
+
+func (ks *keyStore) get(kid string) *signingKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[kid]
+}
+
+func (ks *keyStore) count() int {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return len(ks.keys)
+}
+
+// all retorna as chaves ordenadas da mais nova para a mais antiga
+func (ks *keyStore) all() []*signingKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	list := make([]*signingKey, 0, len(ks.keys))
+	for _, key := range ks.keys {
+		list = append(list, key)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+func readPrivateKey(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("arquivo PEM inválido")
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		return &signingKey{Method: jwt.SigningMethodRS256, Private: k}, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, errors.New("apenas curvas P-256 são suportadas")
+		}
+		return &signingKey{Method: jwt.SigningMethodES256, Private: k}, nil
+	default:
+		return nil, fmt.Errorf("tipo de chave não suportado: %T", parsed)
+	}
+}
@@ -1,24 +1,92 @@
 package auth
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt"
+	"gorm.io/gorm"
+	"log"
 	"net/http"
-	"time"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/ratelimit"
+	"strings"
 )
 
+type LoginRequest struct {
+	Email        string `json:"email" binding:"required,email"`
+	Password     string `json:"password" binding:"required"`
+	OTP          string `json:"otp"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
 func LoginHandler(c *gin.Context) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"userID": "12345",
-		"email":  "user@example.com",
-		"exp":    time.Now().Add(time.Hour * 24).Unix(), // Expira em 24 horas
-	})
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email e senha são obrigatórios"})
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+
+	// Contas bloqueadas por excesso de falhas não chegam a validar a senha
+	locked, err := ratelimit.LoginAccount.Locked(email)
+	if err != nil {
+		log.Printf("Erro ao consultar bloqueio de login: %v", err)
+	}
+	if locked > 0 {
+		ratelimit.TooManyRequests(c, locked)
+		return
+	}
+
+	var user models.User
+	err = database.DB.Where("email = ?", email).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuário"})
+		return
+	}
+	if err != nil || !CheckPassword(user.PasswordHash, req.Password) {
+		loginFailed(c, email, "Credenciais inválidas")
+		return
+	}
+
+	if user.TOTPEnabled {
+		if req.OTP == "" && req.RecoveryCode == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":        "Código de verificação obrigatório",
+				"mfa_required": true,
+			})
+			return
+		}
+		if err := verifySecondFactor(user, req.OTP, req.RecoveryCode); err != nil {
+			loginFailed(c, email, "Código de verificação inválido")
+			return
+		}
+	}
 
-	tokenString, err := token.SignedString(jwtSecret)
+	if err := ratelimit.LoginAccount.Reset(email); err != nil {
+		log.Printf("Erro ao limpar falhas de login: %v", err)
+	}
+
+	tokens, err := issueTokens(user, "", user.TOTPEnabled)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Não foi possível gerar o token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, tokens)
+}
+
+// loginFailed registra a falha para o bloqueio por conta e responde 401, ou
+// 429 se a falha atingiu o limite
+func loginFailed(c *gin.Context, email, message string) {
+	locked, err := ratelimit.LoginAccount.Fail(email)
+	if err != nil {
+		log.Printf("Erro ao registrar falha de login: %v", err)
+	}
+	if locked > 0 {
+		ratelimit.TooManyRequests(c, locked)
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
 }
@@ -0,0 +1,313 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"os"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var errInvalidSecondFactor = errors.New("código de verificação inválido")
+
+// mfaRequiredRoles define quais papéis precisam de 2FA (MFA_REQUIRED_ROLES)
+var mfaRequiredRoles = map[string]bool{models.RoleSuperAdmin: true, models.RoleAdmin: true, models.RoleUploader: true}
+
+func parseRoles(value string, fallback []string) map[string]bool {
+	roles := map[string]bool{}
+	if strings.TrimSpace(value) == "" {
+		for _, role := range fallback {
+			roles[role] = true
+		}
+		return roles
+	}
+	for _, role := range strings.Split(value, ",") {
+		if role = strings.TrimSpace(role); role != "" && role != "none" {
+			roles[role] = true
+		}
+	}
+	return roles
+}
+
+// MFARequired indica se a política exige segundo fator para o papel
+func MFARequired(role string) bool {
+	return mfaRequiredRoles[role]
+}
+
+// RequireMFA bloqueia a rota para usuários cujo papel exige 2FA mas cujo
+// token não comprova o segundo fator. API keys não passam por esta checagem.
+func RequireMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRaw, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+			c.Abort()
+			return
+		}
+		user := userRaw.(UserInfo)
+
+		if user.APIKeyID == 0 && MFARequired(user.Role) && !user.MFA {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":        "Autenticação em dois fatores obrigatória",
+				"mfa_required": true,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// verifySecondFactor valida um código TOTP ou, na falta dele, um código de
+// recuperação (que é consumido)
+func verifySecondFactor(user models.User, otp, recoveryCode string) error {
+	if otp != "" {
+		step, ok := validateTOTP(user.TOTPSecret, otp, time.Now())
+		if !ok {
+			return errInvalidSecondFactor
+		}
+
+		// Um código só pode ser usado uma vez dentro da sua janela de validade
+		key := fmt.Sprintf("auth:totp_used:%d:%d", user.ID, step)
+		fresh, err := queue.RedisClient.SetNX(queue.Ctx, key, 1, (2*totpSkew+1)*totpPeriod*time.Second).Result()
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	if recoveryCode != "" {
+		return consumeRecoveryCode(user.ID, recoveryCode)
+	}
+
+	return errInvalidSecondFactor
+}
+
+func consumeRecoveryCode(userID uint, code string) error {
+	hash := hashRecoveryCode(code)
+
+	result := database.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// replaceRecoveryCodes invalida os códigos anteriores e gera um novo conjunto
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		encoded := hex.EncodeToString(raw)
+		code := encoded[:5] + "-" + encoded[5:]
+
+		if err := tx.Create(&models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)}).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+
+	return codes, nil
+}
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// EnrollTOTPHandler gera um novo segredo TOTP, ainda não ativado, e devolve a
+// URI otpauth para cadastro no app autenticador
+func EnrollTOTPHandler(c *gin.Context) {
+	user, ok := currentUserRecord(c)
+	if !ok {
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA já está ativado"})
+		return
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar segredo"})
+		return
+	}
+
+	user.TOTPSecret = secret
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar segredo"})
+		return
+	}
+
+	issuer := os.Getenv("TOTP_ISSUER")
+	if issuer == "" {
+		issuer = "POC DRM"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": totpURI(issuer, user.Email, secret),
+	})
+}
+
+// ConfirmTOTPHandler ativa o 2FA após validar o primeiro código e retorna os
+// códigos de recuperação, exibidos apenas uma vez
+func ConfirmTOTPHandler(c *gin.Context) {
+	user, ok := currentUserRecord(c)
+	if !ok {
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Código é obrigatório"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "2FA já está ativado"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cadastro de 2FA não iniciado"})
+		return
+	}
+	if err := verifySecondFactor(user, req.Code, ""); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código de verificação inválido"})
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		user.TOTPEnabled = true
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		log.Printf("Erro ao ativar 2FA do usuário %d: %v", user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ativar 2FA"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// RegenerateRecoveryCodesHandler substitui os códigos de recuperação
+func RegenerateRecoveryCodesHandler(c *gin.Context) {
+	user, ok := currentUserRecord(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA não está ativado"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || verifySecondFactor(user, req.Code, "") != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código de verificação inválido"})
+		return
+	}
+
+	var codes []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar códigos de recuperação"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableTOTPHandler desativa o 2FA mediante um código válido
+func DisableTOTPHandler(c *gin.Context) {
+	user, ok := currentUserRecord(c)
+	if !ok {
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "2FA não está ativado"})
+		return
+	}
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || verifySecondFactor(user, req.Code, req.RecoveryCode) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Código de verificação inválido"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		user.TOTPEnabled = false
+		user.TOTPSecret = ""
+		if err := tx.Save(&user).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao desativar 2FA"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// currentUserRecord carrega do banco o usuário autenticado
+func currentUserRecord(c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return user, false
+	}
+
+	if err := database.DB.First(&user, userRaw.(UserInfo).ID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return user, false
+	}
+
+	return user, true
+}
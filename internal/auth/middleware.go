@@ -5,20 +5,42 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt"
+	"log"
+	"math"
 	"net/http"
-	"os"
 	"strings"
+	"time"
 )
 
 type UserInfo struct {
-	ID    string `json:"userID"`
-	Email string `json:"email"`
+	ID        string    `json:"userID"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	TenantID  uint      `json:"tenantID"`
+	Groups    []string  `json:"groups,omitempty"`
+	Scopes    []string  `json:"scopes,omitempty"`
+	MFA       bool      `json:"mfa"`
+	APIKeyID  uint      `json:"-"`
+	TokenID   string    `json:"-"`
+	SessionID string    `json:"-"`
+	ExpiresAt time.Time `json:"-"`
 }
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
 func Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if key := c.GetHeader(apiKeyHeader); key != "" {
+			user, err := authenticateAPIKey(key)
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				c.Abort()
+				return
+			}
+
+			c.Set("user", user)
+			c.Next()
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
@@ -27,12 +49,21 @@ func Middleware() gin.HandlerFunc {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+
+		// Tokens do provedor OIDC são reconhecidos pelo iss; os demais são nossos
+		external := oidc != nil && oidc.issued(tokenString)
+
+		var user UserInfo
+		var claims jwt.MapClaims
+		var err error
+		if external {
+			claims, err = oidc.parse(tokenString)
+		} else {
+			claims, err = parseToken(tokenString)
+			if err == nil && stringClaim(claims, "typ") != tokenTypeAccess {
+				err = errors.New("token não é um access token")
 			}
-			return jwtSecret, nil
-		})
+		}
 
 		if err != nil {
 			var ve *jwt.ValidationError
@@ -47,18 +78,64 @@ func Middleware() gin.HandlerFunc {
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			user := UserInfo{
-				ID:    fmt.Sprintf("%v", claims["userID"]),
-				Email: fmt.Sprintf("%v", claims["email"]),
+		if external {
+			user, err = oidc.userInfo(claims)
+			if err != nil {
+				log.Printf("Erro ao provisionar usuário OIDC: %v", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Não foi possível identificar o usuário"})
+				c.Abort()
+				return
 			}
-			c.Set("user", user)
 		} else {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token inválido"})
+			user = UserInfo{
+				ID:        fmt.Sprintf("%v", claims["userID"]),
+				Email:     fmt.Sprintf("%v", claims["email"]),
+				Role:      stringClaim(claims, "role"),
+				TenantID:  uint(int64Claim(claims, "tenantID")),
+				MFA:       claims["mfa"] == true,
+				TokenID:   stringClaim(claims, "jti"),
+				SessionID: stringClaim(claims, "sid"),
+				ExpiresAt: time.Unix(int64Claim(claims, "exp"), 0),
+			}
+		}
+
+		revoked, err := isRevoked(user.TokenID, user.SessionID, user.ID, millisClaim(claims, "iat"))
+		if err != nil {
+			log.Printf("Erro ao consultar lista de revogação: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível validar o token"})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revogado"})
 			c.Abort()
 			return
 		}
 
+		c.Set("user", user)
 		c.Next()
 	}
 }
+
+func stringClaim(claims jwt.MapClaims, key string) string {
+	if v, ok := claims[key].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func int64Claim(claims jwt.MapClaims, key string) int64 {
+	if v, ok := claims[key].(float64); ok {
+		return int64(v)
+	}
+	return 0
+}
+
+// millisClaim lê uma data do token, que pode ter fração de segundo, em
+// milissegundos
+func millisClaim(claims jwt.MapClaims, key string) int64 {
+	if v, ok := claims[key].(float64); ok {
+		return int64(math.Round(v * 1000))
+	}
+	return 0
+}
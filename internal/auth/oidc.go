@@ -0,0 +1,308 @@
+package auth
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"os"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oidcProvider valida tokens emitidos por um provedor OpenID Connect externo.
+// O discovery document e o JWKS ficam em cache e são renovados
+// periodicamente ou quando aparece um kid desconhecido.
+type oidcProvider struct {
+	issuer          string
+	audience        string
+	client          *http.Client
+	refreshInterval time.Duration
+
+	mu        sync.RWMutex
+	jwksURI   string
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+}
+
+type oidcDiscovery struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+// Intervalo mínimo entre buscas forçadas por kid desconhecido
+const oidcMinRefreshInterval = time.Minute
+
+var oidc *oidcProvider
+
+// InitOIDC habilita a validação de tokens do emissor OIDC_ISSUER, se definido
+func InitOIDC() {
+	issuer := strings.TrimSuffix(os.Getenv("OIDC_ISSUER"), "/")
+	if issuer == "" {
+		return
+	}
+
+	oidc = newOIDCProvider(issuer, os.Getenv("OIDC_AUDIENCE"), durationFromEnv("OIDC_JWKS_REFRESH", time.Hour))
+	if err := oidc.refresh(); err != nil {
+		// O provedor pode estar fora do ar na inicialização; tentamos de novo na primeira requisição
+		log.Printf("Erro ao carregar configuração OIDC de %s: %v", issuer, err)
+		return
+	}
+
+	log.Printf("Autenticação OIDC habilitada para %s", issuer)
+}
+
+func newOIDCProvider(issuer, audience string, refreshInterval time.Duration) *oidcProvider {
+	return &oidcProvider{
+		issuer:          issuer,
+		audience:        audience,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+		keys:            map[string]crypto.PublicKey{},
+	}
+}
+
+// issued indica se o token foi emitido por este provedor, sem validá-lo
+func (p *oidcProvider) issued(tokenString string) bool {
+	claims := jwt.MapClaims{}
+	if _, _, err := new(jwt.Parser).ParseUnverified(tokenString, claims); err != nil {
+		return false
+	}
+	return strings.TrimSuffix(stringClaim(claims, "iss"), "/") == p.issuer
+}
+
+// parse valida assinatura, emissor, audiência e expiração do token
+func (p *oidcProvider) parse(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA, *jwt.SigningMethodRSAPSS:
+		default:
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		return p.key(kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("token inválido")
+	}
+
+	if strings.TrimSuffix(stringClaim(claims, "iss"), "/") != p.issuer {
+		return nil, errors.New("emissor inválido")
+	}
+	if p.audience != "" && !claims.VerifyAudience(p.audience, true) {
+		return nil, errors.New("audiência inválida")
+	}
+	if stringClaim(claims, "sub") == "" {
+		return nil, errors.New("claim sub ausente")
+	}
+
+	return claims, nil
+}
+
+func (p *oidcProvider) key(kid string) (crypto.PublicKey, error) {
+	p.mu.RLock()
+	key, ok := p.keys[kid]
+	stale := time.Since(p.fetchedAt) > p.refreshInterval
+	recent := time.Since(p.fetchedAt) < oidcMinRefreshInterval
+	p.mu.RUnlock()
+
+	if ok && !stale {
+		return key, nil
+	}
+
+	// Kid desconhecido pode indicar rotação de chaves no provedor
+	if !ok && recent {
+		return nil, fmt.Errorf("unknown key id: %q", kid)
+	}
+
+	if err := p.refresh(); err != nil {
+		if ok {
+			log.Printf("Erro ao renovar JWKS do OIDC, usando cache: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key id: %q", kid)
+}
+
+// refresh busca o discovery document (se necessário) e o JWKS do provedor
+func (p *oidcProvider) refresh() error {
+	p.mu.RLock()
+	jwksURI := p.jwksURI
+	p.mu.RUnlock()
+
+	if jwksURI == "" {
+		var discovery oidcDiscovery
+		if err := p.getJSON(p.issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return fmt.Errorf("erro no discovery: %v", err)
+		}
+		if strings.TrimSuffix(discovery.Issuer, "/") != p.issuer {
+			return fmt.Errorf("issuer do discovery (%s) difere do configurado", discovery.Issuer)
+		}
+		if discovery.JWKSURI == "" {
+			return errors.New("discovery sem jwks_uri")
+		}
+		jwksURI = discovery.JWKSURI
+	}
+
+	var set JWKS
+	if err := p.getJSON(jwksURI, &set); err != nil {
+		return fmt.Errorf("erro ao buscar JWKS: %v", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			log.Printf("Ignorando chave OIDC %s: %v", jwk.Kid, err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	p.mu.Lock()
+	p.jwksURI = jwksURI
+	p.keys = keys
+	p.fetchedAt = time.Now()
+	p.mu.Unlock()
+
+	return nil
+}
+
+func (p *oidcProvider) getJSON(url string, v interface{}) error {
+	resp, err := p.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s retornou status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// userInfo converte as claims do provedor em UserInfo, criando o usuário
+// local no primeiro acesso
+func (p *oidcProvider) userInfo(claims jwt.MapClaims) (UserInfo, error) {
+	user, err := p.provision(claims)
+	if err != nil {
+		return UserInfo{}, err
+	}
+
+	return UserInfo{
+		ID:        fmt.Sprintf("%d", user.ID),
+		Email:     user.Email,
+		Role:      user.Role,
+		TenantID:  user.TenantID,
+		Groups:    groupsClaim(claims),
+		MFA:       mfaClaim(claims),
+		TokenID:   stringClaim(claims, "jti"),
+		SessionID: stringClaim(claims, "sid"),
+		ExpiresAt: time.Unix(int64Claim(claims, "exp"), 0),
+	}, nil
+}
+
+func (p *oidcProvider) provision(claims jwt.MapClaims) (models.User, error) {
+	externalID := p.issuer + "|" + stringClaim(claims, "sub")
+	email := strings.ToLower(stringClaim(claims, "email"))
+
+	var user models.User
+	err := database.DB.Where("external_id = ?", externalID).First(&user).Error
+	if err == nil {
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, err
+	}
+
+	// Vincula a uma conta local existente apenas se o provedor confirmou o email
+	if verified, _ := claims["email_verified"].(bool); verified && email != "" {
+		err = database.DB.Where("email = ? AND (external_id = '' OR external_id IS NULL)", email).First(&user).Error
+		if err == nil {
+			user.ExternalID = externalID
+			return user, database.DB.Save(&user).Error
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, err
+		}
+	}
+
+	if email == "" {
+		email = externalID
+	}
+	name := stringClaim(claims, "name")
+	if name == "" {
+		name = email
+	}
+
+	user = models.User{
+		Name:       name,
+		Email:      email,
+		ExternalID: externalID,
+		Role:       models.RoleViewer,
+		TenantID:   database.DefaultTenantID,
+	}
+	if err := database.DB.Create(&user).Error; err != nil {
+		return user, fmt.Errorf("erro ao criar usuário OIDC: %v", err)
+	}
+
+	log.Printf("Usuário OIDC provisionado: %s (%s)", user.Email, externalID)
+	return user, nil
+}
+
+// groupsClaim aceita groups como lista ou como string separada por vírgulas
+func groupsClaim(claims jwt.MapClaims) []string {
+	var groups []string
+	switch v := claims["groups"].(type) {
+	case []interface{}:
+		for _, g := range v {
+			if s, ok := g.(string); ok && s != "" {
+				groups = append(groups, s)
+			}
+		}
+	case string:
+		for _, g := range strings.Split(v, ",") {
+			if g = strings.TrimSpace(g); g != "" {
+				groups = append(groups, g)
+			}
+		}
+	}
+	return groups
+}
+
+// mfaClaim interpreta a claim amr (RFC 8176) para saber se o provedor
+// exigiu um segundo fator no login
+func mfaClaim(claims jwt.MapClaims) bool {
+	methods, _ := claims["amr"].([]interface{})
+	for _, m := range methods {
+		switch m {
+		case "mfa", "otp", "hwk", "swk":
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,344 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// fakeIssuer é um provedor OIDC local com discovery e JWKS trocáveis
+type fakeIssuer struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PrivateKey
+	discovery map[string]string
+
+	discoveryHits atomic.Int32
+	jwksHits      atomic.Int32
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	t.Helper()
+
+	f := &fakeIssuer{keys: map[string]*rsa.PrivateKey{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		f.discoveryHits.Add(1)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		json.NewEncoder(w).Encode(f.discovery)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		f.jwksHits.Add(1)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		set := JWKS{Keys: []JWK{}}
+		for kid, key := range f.keys {
+			set.Keys = append(set.Keys, publicJWK(&signingKey{ID: kid, Method: jwt.SigningMethodRS256, Private: key}))
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+
+	f.discovery = map[string]string{"issuer": f.URL, "jwks_uri": f.URL + "/jwks"}
+	return f
+}
+
+// rotate publica apenas uma nova chave com o kid informado
+func (f *fakeIssuer) rotate(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("gerar chave: %v", err)
+	}
+	f.mu.Lock()
+	f.keys = map[string]*rsa.PrivateKey{kid: key}
+	f.mu.Unlock()
+}
+
+// sign emite um token com as claims padrão do emissor sobrescritas por extra
+func (f *fakeIssuer) sign(t *testing.T, kid string, extra jwt.MapClaims) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{
+		"iss": f.URL,
+		"aud": "drm-api",
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+		"iat": time.Now().Unix(),
+	}
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	f.mu.Lock()
+	key := f.keys[kid]
+	f.mu.Unlock()
+	if key == nil {
+		// Chave fora do JWKS publicado
+		var err error
+		if key, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatalf("gerar chave: %v", err)
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("assinar token: %v", err)
+	}
+	return signed
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.rotate(t, "k1")
+
+	p := newOIDCProvider(issuer.URL, "drm-api", time.Hour)
+	if err := p.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if p.jwksURI != issuer.URL+"/jwks" {
+		t.Errorf("jwksURI = %q", p.jwksURI)
+	}
+	if _, ok := p.keys["k1"]; !ok {
+		t.Errorf("chave k1 não carregada: %v", p.keys)
+	}
+
+	// O discovery só é buscado uma vez
+	if err := p.refresh(); err != nil {
+		t.Fatalf("segundo refresh: %v", err)
+	}
+	if hits := issuer.discoveryHits.Load(); hits != 1 {
+		t.Errorf("discovery buscado %d vezes", hits)
+	}
+	if hits := issuer.jwksHits.Load(); hits != 2 {
+		t.Errorf("JWKS buscado %d vezes", hits)
+	}
+}
+
+func TestOIDCDiscoveryRejectsInvalidDocument(t *testing.T) {
+	tests := []struct {
+		name      string
+		discovery func(url string) map[string]string
+	}{
+		{"outro issuer", func(url string) map[string]string {
+			return map[string]string{"issuer": "https://outro.example.com", "jwks_uri": url + "/jwks"}
+		}},
+		{"sem jwks_uri", func(url string) map[string]string {
+			return map[string]string{"issuer": url}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := newFakeIssuer(t)
+			issuer.rotate(t, "k1")
+			issuer.discovery = tt.discovery(issuer.URL)
+
+			p := newOIDCProvider(issuer.URL, "", time.Hour)
+			if err := p.refresh(); err == nil {
+				t.Fatal("discovery inválido aceito")
+			}
+			if p.jwksURI != "" {
+				t.Errorf("jwksURI = %q", p.jwksURI)
+			}
+		})
+	}
+}
+
+func TestOIDCRefetchesJWKSOnUnknownKid(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.rotate(t, "k1")
+
+	p := newOIDCProvider(issuer.URL, "drm-api", time.Hour)
+	if err := p.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if _, err := p.parse(issuer.sign(t, "k1", nil)); err != nil {
+		t.Fatalf("token com k1 rejeitado: %v", err)
+	}
+
+	issuer.rotate(t, "k2")
+	token := issuer.sign(t, "k2", nil)
+
+	// Menos de um minuto após a última busca o kid desconhecido é recusado
+	// sem consultar o provedor
+	if _, err := p.parse(token); err == nil {
+		t.Fatal("kid desconhecido aceito antes do intervalo mínimo")
+	}
+	if hits := issuer.jwksHits.Load(); hits != 1 {
+		t.Fatalf("JWKS buscado %d vezes dentro do intervalo mínimo", hits)
+	}
+
+	p.mu.Lock()
+	p.fetchedAt = time.Now().Add(-2 * oidcMinRefreshInterval)
+	p.mu.Unlock()
+
+	if _, err := p.parse(token); err != nil {
+		t.Fatalf("token com k2 rejeitado após a rotação: %v", err)
+	}
+	if hits := issuer.jwksHits.Load(); hits != 2 {
+		t.Errorf("JWKS buscado %d vezes", hits)
+	}
+
+	// Kid que o provedor também não conhece continua recusado
+	p.mu.Lock()
+	p.fetchedAt = time.Now().Add(-2 * oidcMinRefreshInterval)
+	p.mu.Unlock()
+	if _, err := p.parse(issuer.sign(t, "k3", nil)); err == nil {
+		t.Fatal("token assinado com chave desconhecida aceito")
+	}
+}
+
+func TestOIDCRejectsInvalidClaims(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.rotate(t, "k1")
+
+	p := newOIDCProvider(issuer.URL, "drm-api", time.Hour)
+	if err := p.refresh(); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+	}{
+		{"emissor", jwt.MapClaims{"iss": "https://outro.example.com"}},
+		{"audiência", jwt.MapClaims{"aud": "outra-api"}},
+		{"expirado", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}},
+		{"sem sub", jwt.MapClaims{"sub": ""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := p.parse(issuer.sign(t, "k1", tt.claims)); err == nil {
+				t.Fatal("token aceito")
+			}
+		})
+	}
+
+	if _, err := p.parse(issuer.sign(t, "k1", jwt.MapClaims{"aud": []interface{}{"outra-api", "drm-api"}})); err != nil {
+		t.Errorf("token com a audiência em uma lista rejeitado: %v", err)
+	}
+}
+
+func TestOIDCProvisionsUserOnFirstLogin(t *testing.T) {
+	setupTestDB(t)
+	p := newOIDCProvider("https://idp.example.com", "", time.Hour)
+
+	claims := jwt.MapClaims{"sub": "abc", "email": "Nova@Example.com", "name": "Nova"}
+	first, err := p.provision(claims)
+	if err != nil {
+		t.Fatalf("provisionar: %v", err)
+	}
+	if first.Email != "nova@example.com" || first.Name != "Nova" ||
+		first.ExternalID != "https://idp.example.com|abc" ||
+		first.Role != models.RoleViewer || first.TenantID != database.DefaultTenantID {
+		t.Fatalf("usuário provisionado = %+v", first)
+	}
+
+	again, err := p.provision(claims)
+	if err != nil {
+		t.Fatalf("segundo login: %v", err)
+	}
+	if again.ID != first.ID {
+		t.Errorf("segundo login criou outro usuário (%d != %d)", again.ID, first.ID)
+	}
+}
+
+func TestOIDCLinksLocalAccountOnlyWithVerifiedEmail(t *testing.T) {
+	setupTestDB(t)
+	p := newOIDCProvider("https://idp.example.com", "", time.Hour)
+
+	local := models.User{Email: "local@example.com", Role: models.RoleUploader, TenantID: 1}
+	if err := database.DB.Create(&local).Error; err != nil {
+		t.Fatalf("criar usuário local: %v", err)
+	}
+
+	for _, verified := range []interface{}{nil, false, "true"} {
+		claims := jwt.MapClaims{"sub": "abc", "email": "local@example.com"}
+		if verified != nil {
+			claims["email_verified"] = verified
+		}
+		if user, err := p.provision(claims); err == nil && user.ID == local.ID {
+			t.Fatalf("conta local vinculada com email_verified=%v", verified)
+		}
+
+		var reloaded models.User
+		database.DB.First(&reloaded, local.ID)
+		if reloaded.ExternalID != "" {
+			t.Fatalf("external_id gravado com email_verified=%v", verified)
+		}
+	}
+
+	user, err := p.provision(jwt.MapClaims{"sub": "abc", "email": "LOCAL@example.com", "email_verified": true})
+	if err != nil {
+		t.Fatalf("vincular: %v", err)
+	}
+	if user.ID != local.ID || user.Role != models.RoleUploader || user.ExternalID != "https://idp.example.com|abc" {
+		t.Fatalf("usuário vinculado = %+v", user)
+	}
+}
+
+func TestOIDCUserInfoReadsAMR(t *testing.T) {
+	setupTestDB(t)
+	p := newOIDCProvider("https://idp.example.com", "", time.Hour)
+
+	tests := []struct {
+		amr interface{}
+		mfa bool
+	}{
+		{nil, false},
+		{[]interface{}{"pwd"}, false},
+		{[]interface{}{"pwd", "otp"}, true},
+		{[]interface{}{"hwk"}, true},
+		{[]interface{}{"mfa"}, true},
+		{"mfa", false},
+	}
+
+	for _, tt := range tests {
+		claims := jwt.MapClaims{"sub": "abc", "email": "amr@example.com", "exp": float64(time.Now().Add(time.Hour).Unix())}
+		if tt.amr != nil {
+			claims["amr"] = tt.amr
+		}
+		info, err := p.userInfo(claims)
+		if err != nil {
+			t.Fatalf("userInfo: %v", err)
+		}
+		if info.MFA != tt.mfa {
+			t.Errorf("amr=%v: MFA = %t, esperado %t", tt.amr, info.MFA, tt.mfa)
+		}
+	}
+}
+
+func TestOIDCIssued(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	issuer.rotate(t, "k1")
+	p := newOIDCProvider(issuer.URL, "", time.Hour)
+
+	if !p.issued(issuer.sign(t, "k1", jwt.MapClaims{"iss": issuer.URL + "/"})) {
+		t.Error("token do provedor não reconhecido")
+	}
+	if p.issued(issuer.sign(t, "k1", jwt.MapClaims{"iss": "https://outro.example.com"})) {
+		t.Error("token de outro emissor reconhecido")
+	}
+	if p.issued(strings.Repeat("x", 20)) {
+		t.Error("texto qualquer reconhecido como token")
+	}
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+)
+
+type RegisterRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+func RegisterHandler(c *gin.Context) {
+	var req RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome, email e senha (mínimo 8 caracteres) são obrigatórios"})
+		return
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	var existing models.User
+	err := database.DB.Where("email = ?", email).First(&existing).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar existência do usuário"})
+		return
+	}
+	if err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Email já cadastrado"})
+		return
+	}
+
+	hash, err := HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao processar senha"})
+		return
+	}
+
+	user := models.User{
+		Name:         strings.TrimSpace(req.Name),
+		Email:        email,
+		PasswordHash: hash,
+		Role:         models.RoleViewer,
+		TenantID:     database.DefaultTenantID,
+	}
+
+	if err := database.DB.Create(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar usuário"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
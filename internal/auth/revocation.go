@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"projeto_drm/poc/internal/queue"
+	"strconv"
+	"time"
+)
+
+// As chaves de revogação expiram junto com o token mais longo que podem
+// afetar, então a lista nunca cresce indefinidamente.
+func revokedJTIKey(jti string) string       { return fmt.Sprintf("auth:revoked:jti:%s", jti) }
+func revokedSessionKey(sid string) string   { return fmt.Sprintf("auth:revoked:sid:%s", sid) }
+func revokedBeforeKey(userID string) string { return fmt.Sprintf("auth:revoked_before:%s", userID) }
+
+// RevokeToken invalida um token específico até a sua expiração
+func RevokeToken(jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return queue.RedisClient.Set(queue.Ctx, revokedJTIKey(jti), 1, ttl).Err()
+}
+
+// claimToken revoga o token e retorna true apenas para quem o revogou
+// primeiro; usado na rotação para que um refresh token seja trocado uma vez só
+// mesmo com requisições concorrentes
+func claimToken(jti string, expiresAt time.Time) (bool, error) {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return false, nil
+	}
+	return queue.RedisClient.SetNX(queue.Ctx, revokedJTIKey(jti), 1, ttl).Result()
+}
+
+// RevokeSession invalida todos os tokens (access e refresh) de uma sessão
+func RevokeSession(sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return queue.RedisClient.Set(queue.Ctx, revokedSessionKey(sessionID), 1, refreshTokenTTL).Err()
+}
+
+// RevokeUserSessions invalida todos os tokens emitidos até agora para o usuário.
+// O corte é gravado em milissegundos, como o iat dos tokens emitidos aqui, para
+// que um login logo após a revogação não seja revogado junto.
+func RevokeUserSessions(userID string) error {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	return queue.RedisClient.Set(queue.Ctx, revokedBeforeKey(userID), now, refreshTokenTTL).Err()
+}
+
+// isRevoked consulta a lista de revogação para o jti, a sessão e o usuário;
+// issuedAt é o iat do token em milissegundos
+func isRevoked(jti, sessionID, userID string, issuedAt int64) (bool, error) {
+	values, err := queue.RedisClient.MGet(queue.Ctx,
+		revokedJTIKey(jti),
+		revokedSessionKey(sessionID),
+		revokedBeforeKey(userID),
+	).Result()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return false, err
+	}
+
+	if values[0] != nil || values[1] != nil {
+		return true, nil
+	}
+
+	if before, ok := values[2].(string); ok {
+		cutoff, err := strconv.ParseInt(before, 10, 64)
+		if err == nil && issuedAt < cutoff {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
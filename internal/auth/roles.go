@@ -0,0 +1,49 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"projeto_drm/poc/internal/models"
+)
+
+// RequireRole permite a requisição apenas se o usuário autenticado possuir um
+// dos papéis informados. O superadmin tem acesso a todas as rotas e o admin a
+// todas exceto as exclusivas do superadmin.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userRaw, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+			c.Abort()
+			return
+		}
+		user := userRaw.(UserInfo)
+
+		if user.Role == models.RoleSuperAdmin {
+			c.Next()
+			return
+		}
+
+		platformOnly := false
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+			platformOnly = platformOnly || role == models.RoleSuperAdmin
+		}
+
+		if user.Role == models.RoleAdmin && !platformOnly {
+			c.Next()
+			return
+		}
+
+		Forbidden(c)
+	}
+}
+
+// Forbidden responde 403 com o corpo de erro padrão da API
+func Forbidden(c *gin.Context) {
+	c.JSON(http.StatusForbidden, gin.H{"error": "Acesso negado"})
+	c.Abort()
+}
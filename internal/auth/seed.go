@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+)
+
+// SeedAdmin cria o administrador da plataforma a partir de ADMIN_EMAIL e
+// ADMIN_PASSWORD, caso ainda não exista um usuário com esse email.
+func SeedAdmin() {
+	email := strings.ToLower(strings.TrimSpace(os.Getenv("ADMIN_EMAIL")))
+	password := os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return
+	}
+
+	var existing models.User
+	err := database.DB.Where("email = ?", email).First(&existing).Error
+	if err == nil {
+		return
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Erro ao verificar administrador inicial: %v", err)
+		return
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		log.Printf("Erro ao gerar hash da senha do administrador: %v", err)
+		return
+	}
+
+	admin := models.User{
+		Name:         "Administrador",
+		Email:        email,
+		PasswordHash: hash,
+		Role:         models.RoleSuperAdmin,
+		TenantID:     database.DefaultTenantID,
+	}
+	if err := database.DB.Create(&admin).Error; err != nil {
+		log.Printf("Erro ao criar administrador inicial: %v", err)
+		return
+	}
+
+	log.Println("Administrador inicial criado:", email)
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"time"
+)
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshHandler troca um refresh token válido por um novo par de tokens.
+// O refresh token usado é revogado (rotação), mantendo a mesma sessão.
+func RefreshHandler(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token é obrigatório"})
+		return
+	}
+
+	claims, err := parseToken(req.RefreshToken)
+	if err != nil || stringClaim(claims, "typ") != tokenTypeRefresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token inválido"})
+		return
+	}
+
+	jti := stringClaim(claims, "jti")
+	sessionID := stringClaim(claims, "sid")
+	userID := stringClaim(claims, "userID")
+
+	revoked, err := isRevoked(jti, sessionID, userID, millisClaim(claims, "iat"))
+	if err != nil {
+		log.Printf("Erro ao consultar lista de revogação: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível validar o token"})
+		return
+	}
+	if revoked {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revogado"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não encontrado"})
+		return
+	}
+
+	// Revoga o token antes de emitir o novo par; entre requisições concorrentes
+	// com o mesmo refresh token só a primeira renova a sessão
+	claimed, err := claimToken(jti, time.Unix(int64Claim(claims, "exp"), 0))
+	if err != nil {
+		log.Printf("Erro ao revogar refresh token: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível renovar a sessão"})
+		return
+	}
+	if !claimed {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revogado"})
+		return
+	}
+
+	mfa, _ := claims["mfa"].(bool)
+	tokens, err := issueTokens(user, sessionID, mfa)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Não foi possível gerar o token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// LogoutHandler encerra a sessão do token atual, invalidando também o
+// refresh token associado a ela.
+func LogoutHandler(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(UserInfo)
+
+	if err := RevokeToken(user.TokenID, user.ExpiresAt); err != nil {
+		log.Printf("Erro ao revogar token: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível encerrar a sessão"})
+		return
+	}
+	if err := RevokeSession(user.SessionID); err != nil {
+		log.Printf("Erro ao revogar sessão: %v", err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível encerrar a sessão"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAllHandler encerra todas as sessões do usuário autenticado
+func LogoutAllHandler(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(UserInfo)
+
+	if err := RevokeUserSessions(user.ID); err != nil {
+		log.Printf("Erro ao revogar sessões do usuário %s: %v", user.ID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível encerrar as sessões"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"path/filepath"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// setupTestDB troca o banco global por um SQLite exclusivo do teste
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("abrir banco: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.APIKey{}); err != nil {
+		t.Fatalf("migrar banco: %v", err)
+	}
+	database.DB = db
+	database.DefaultTenantID = 1
+}
@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"fmt"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"log"
+	"os"
+	"projeto_drm/poc/internal/models"
+	"time"
+)
+
+const (
+	tokenTypeAccess  = "access"
+	tokenTypeRefresh = "refresh"
+)
+
+var (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 7 * 24 * time.Hour
+)
+
+// TokenPair é o par de tokens entregue no login e na renovação da sessão
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// issueTokens gera um access token de curta duração e um refresh token para
+// a sessão informada. Uma sessão vazia inicia uma nova sessão; mfa indica se
+// o segundo fator foi validado no login que originou a sessão.
+func issueTokens(user models.User, sessionID string, mfa bool) (TokenPair, error) {
+	if sessionID == "" {
+		sessionID = uuid.New().String()
+	}
+	now := time.Now()
+	// iat com fração de segundo, comparado em milissegundos com o corte de
+	// RevokeUserSessions
+	issuedAt := float64(now.UnixMilli()) / 1000
+
+	accessString, err := signToken(jwt.MapClaims{
+		"userID":   fmt.Sprintf("%d", user.ID),
+		"email":    user.Email,
+		"role":     user.Role,
+		"tenantID": user.TenantID,
+		"mfa":      mfa,
+		"typ":      tokenTypeAccess,
+		"jti":      uuid.New().String(),
+		"sid":      sessionID,
+		"iat":      issuedAt,
+		"exp":      now.Add(accessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshString, err := signToken(jwt.MapClaims{
+		"userID": fmt.Sprintf("%d", user.ID),
+		"mfa":    mfa,
+		"typ":    tokenTypeRefresh,
+		"jti":    uuid.New().String(),
+		"sid":    sessionID,
+		"iat":    issuedAt,
+		"exp":    now.Add(refreshTokenTTL).Unix(),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessString,
+		RefreshToken: refreshString,
+		ExpiresIn:    int64(accessTokenTTL.Seconds()),
+	}, nil
+}
+
+// signToken assina as claims com a chave ativa, identificada no header kid
+func signToken(claims jwt.MapClaims) (string, error) {
+	key := keys.current()
+	if key == nil {
+		return "", errNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// parseToken valida a assinatura e a expiração do token usando a chave
+// indicada pelo kid
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := keys.get(kid)
+		if key == nil {
+			return nil, fmt.Errorf("unknown key id: %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.Private.Public(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("token inválido")
+	}
+
+	return claims, nil
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Valor inválido para %s (%q), usando %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
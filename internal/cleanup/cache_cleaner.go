@@ -2,9 +2,10 @@ package cleanup
 
 import (
 	"log"
-	"os"
 	"projeto_drm/poc/internal/database"
 	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
 	"time"
 )
 
@@ -35,7 +36,7 @@ func cleanupOldCache(maxAge time.Duration) {
 	cleaned := 0
 	for _, pa := range processedAssets {
 		if pa.CachePath != "" {
-			if err := os.Remove(pa.CachePath); err != nil && !os.IsNotExist(err) {
+			if err := storage.Default.Delete(queue.Ctx, pa.CachePath); err != nil {
 				log.Printf("Error removing cache file %s: %v", pa.CachePath, err)
 				continue
 			}
@@ -0,0 +1,14 @@
+package cleanup
+
+import "time"
+
+// StartTrashCleanup executa purge periodicamente. O expurgo em si fica com
+// os handlers, que conhecem a contagem de referências dos blobs.
+func StartTrashCleanup(interval time.Duration, purge func()) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			purge()
+		}
+	}()
+}
@@ -0,0 +1,70 @@
+package cleanup
+
+import (
+	"errors"
+	"log"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
+	"time"
+)
+
+// completionTimeout é o prazo após o qual uma conclusão de upload ainda em
+// andamento é considerada abandonada (processo reiniciado durante a montagem)
+const completionTimeout = 2 * time.Hour
+
+// StartUploadCleanup descarta periodicamente as partes de uploads resumíveis
+// que expiraram sem ser concluídos ou cuja conclusão foi interrompida
+func StartUploadCleanup(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		for range ticker.C {
+			cleanupExpiredUploads()
+		}
+	}()
+}
+
+func cleanupExpiredUploads() {
+	var sessions []models.UploadSession
+	err := database.DB.
+		Where("(status = ? AND expires_at < ?) OR (status = ? AND updated_at < ?)",
+			models.UploadInProgress, time.Now(), models.UploadCompleting, time.Now().Add(-completionTimeout)).
+		Find(&sessions).Error
+	if err != nil {
+		log.Printf("Error finding expired uploads: %v", err)
+		return
+	}
+
+	cleaned := 0
+	for _, session := range sessions {
+		var chunks []models.UploadChunk
+		if err := database.DB.Where("upload_id = ?", session.UploadID).Find(&chunks).Error; err != nil {
+			log.Printf("Error finding chunks of upload %s: %v", session.UploadID, err)
+			continue
+		}
+
+		failed := false
+		for _, chunk := range chunks {
+			if err := storage.Default.Delete(queue.Ctx, chunk.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+				log.Printf("Error removing upload chunk %s: %v", chunk.Key, err)
+				failed = true
+			}
+		}
+		if failed {
+			continue
+		}
+
+		database.DB.Where("upload_id = ?", session.UploadID).Delete(&models.UploadChunk{})
+		reason := "Sessão de upload expirada"
+		if session.Status == models.UploadCompleting {
+			reason = "Conclusão do upload interrompida"
+		}
+		database.DB.Model(&session).Updates(models.UploadSession{Status: models.UploadFailed, Error: reason})
+		cleaned++
+	}
+
+	if cleaned > 0 {
+		log.Printf("Upload cleanup completed. Expired %d uploads", cleaned)
+	}
+}
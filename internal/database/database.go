@@ -10,6 +10,10 @@ import (
 
 var DB *gorm.DB
 
+// DefaultTenantID é o tenant que recebe novos cadastros e os dados criados
+// antes da existência de tenants
+var DefaultTenantID uint
+
 func InitDatabase() {
 	var err error
 
@@ -20,10 +24,50 @@ func InitDatabase() {
 
 	DB = db
 
-	err = DB.AutoMigrate(&models.Asset{}, &models.ProcessedAsset{})
+	err = DB.AutoMigrate(
+		&models.Asset{},
+		&models.ProcessedAsset{},
+		&models.User{},
+		&models.AssetGrant{},
+		&models.GroupMembership{},
+		&models.APIKey{},
+		&models.RecoveryCode{},
+		&models.Tenant{},
+		&models.UploadSession{},
+		&models.UploadChunk{},
+		&models.Blob{},
+		&models.AssetTag{},
+		&models.TechnicalMetadata{},
+		&models.AssetVersion{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+	)
 	if err != nil {
 		log.Fatalf("Error migrating database: %v", err)
 	}
 
+	if err := ensureDefaultTenant(); err != nil {
+		log.Fatalf("Error creating default tenant: %v", err)
+	}
+
 	fmt.Println("Database connected and migrated successfully.")
 }
+
+// ensureDefaultTenant cria o tenant padrão e associa a ele os registros que
+// ainda não pertencem a nenhum tenant
+func ensureDefaultTenant() error {
+	tenant := models.Tenant{Name: "Default", Slug: models.DefaultTenantSlug}
+	if err := DB.Where("slug = ?", tenant.Slug).FirstOrCreate(&tenant).Error; err != nil {
+		return err
+	}
+	DefaultTenantID = tenant.ID
+
+	for _, model := range []interface{}{&models.User{}, &models.Asset{}, &models.ProcessedAsset{}} {
+		err := DB.Model(model).Where("tenant_id = 0 OR tenant_id IS NULL").Update("tenant_id", tenant.ID).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package extractor
+
+import (
+	"context"
+	"os"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/storage"
+	"projeto_drm/poc/internal/validation"
+	"strings"
+	"time"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
+	"gorm.io/gorm/clause"
+)
+
+// timeout limita o pdfcpu e o ffprobe em arquivos malformados ou muito grandes
+const timeout = 2 * time.Minute
+
+// ExtractAsset extrai os metadados técnicos do arquivo do asset e os grava,
+// substituindo uma extração anterior. Tipos sem extração são ignorados.
+func ExtractAsset(ctx context.Context, asset models.Asset) error {
+	if !Supported(asset.Type) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	localPath, cleanup, err := storage.FetchToFile(ctx, storage.Default, asset.Path)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	meta, err := Extract(ctx, localPath, asset.Type)
+	if err != nil {
+		return err
+	}
+	meta.AssetID = asset.ID
+
+	return database.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "asset_id"}},
+		UpdateAll: true,
+	}).Create(&meta).Error
+}
+
+// Supported indica se há extração de metadados técnicos para o tipo
+func Supported(contentType string) bool {
+	return contentType == "application/pdf" || strings.HasPrefix(contentType, "video/")
+}
+
+// Extract lê os metadados técnicos do arquivo local em path, sem AssetID
+func Extract(ctx context.Context, path, contentType string) (models.TechnicalMetadata, error) {
+	if contentType == "application/pdf" {
+		return extractPDF(path)
+	}
+	return extractVideo(ctx, path)
+}
+
+// extractPDF usa o pdfcpu para obter versão, páginas, tamanho da primeira
+// página, criptografia e o software que gerou o arquivo
+func extractPDF(path string) (models.TechnicalMetadata, error) {
+	meta := models.TechnicalMetadata{Kind: "pdf", ExtractedAt: time.Now()}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return meta, err
+	}
+	defer f.Close()
+
+	conf := model.NewDefaultConfiguration()
+	conf.ValidationMode = model.ValidationRelaxed
+
+	info, err := api.PDFInfo(f, path, []string{"1"}, conf)
+	if err != nil {
+		return meta, err
+	}
+
+	meta.PDFVersion = info.Version
+	meta.PageCount = info.PageCount
+	meta.Encrypted = info.Encrypted
+	meta.Producer = info.Producer
+	if len(info.PageBoundaries) > 0 && info.PageBoundaries[0].Media != nil {
+		dim := info.PageBoundaries[0].MediaBox().Dimensions()
+		meta.PageWidth = dim.Width
+		meta.PageHeight = dim.Height
+	}
+	return meta, nil
+}
+
+// extractVideo usa o ffprobe para obter duração, resolução, codecs, taxa de
+// bits e trilhas de áudio
+func extractVideo(ctx context.Context, path string) (models.TechnicalMetadata, error) {
+	meta := models.TechnicalMetadata{Kind: "video", ExtractedAt: time.Now()}
+
+	probe, err := validation.ProbeVideo(ctx, path)
+	if err != nil {
+		return meta, err
+	}
+
+	meta.DurationSeconds = probe.Duration()
+	meta.Bitrate = probe.BitRate()
+	for _, stream := range probe.Streams {
+		switch stream.CodecType {
+		case "video":
+			// Capas embutidas também aparecem como trilha de vídeo; vale a primeira
+			if meta.VideoCodec == "" {
+				meta.VideoCodec = stream.CodecName
+				meta.Width = stream.Width
+				meta.Height = stream.Height
+			}
+		case "audio":
+			meta.AudioTracks = append(meta.AudioTracks, models.AudioTrack{
+				Codec:    stream.CodecName,
+				Channels: stream.Channels,
+				Language: stream.Tags.Language,
+			})
+		}
+	}
+	return meta, nil
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// tenantScope restringe a consulta ao tenant do usuário; o superadmin
+// enxerga todos os tenants
+func tenantScope(db *gorm.DB, user auth.UserInfo, table string) *gorm.DB {
+	if user.Role == models.RoleSuperAdmin {
+		return db
+	}
+	return db.Where(table+".tenant_id = ?", user.TenantID)
+}
+
+// sameTenant indica se o usuário pode atuar sobre um registro do tenant informado
+func sameTenant(user auth.UserInfo, tenantID uint) bool {
+	return user.Role == models.RoleSuperAdmin || user.TenantID == tenantID
+}
+
+// visibleAssets restringe a consulta aos assets que o usuário pode ver:
+// administradores veem tudo do seu tenant, os demais veem os próprios uploads
+// e os assets compartilhados com eles (diretamente ou via grupo) cujo acesso
+// não expirou.
+func visibleAssets(db *gorm.DB, user auth.UserInfo) *gorm.DB {
+	db = tenantScope(db, user, "assets")
+	if user.Role == models.RoleAdmin || user.Role == models.RoleSuperAdmin {
+		return db
+	}
+
+	userID, err := strconv.ParseUint(user.ID, 10, 64)
+	if err != nil {
+		return db.Where("1 = 0")
+	}
+
+	return db.Where("assets.owner_id = ? OR assets.id IN (?)", userID, activeGrants(userID, user.Groups).Select("asset_id"))
+}
+
+// activeGrants retorna os grants válidos do usuário, incluindo os dos grupos
+// cadastrados localmente e dos grupos informados pelo token (OIDC)
+func activeGrants(userID uint64, tokenGroups []string) *gorm.DB {
+	groups := database.DB.Model(&models.GroupMembership{}).
+		Select("group_name").
+		Where("user_id = ?", userID)
+
+	query := database.DB.Model(&models.AssetGrant{}).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+
+	if len(tokenGroups) > 0 {
+		return query.Where("user_id = ? OR group_name IN (?) OR group_name IN ?", userID, groups, tokenGroups)
+	}
+	return query.Where("user_id = ? OR group_name IN (?)", userID, groups)
+}
+
+// canAccessAsset indica se o usuário pode visualizar e baixar o asset
+func canAccessAsset(user auth.UserInfo, asset models.Asset) bool {
+	if !sameTenant(user, asset.TenantID) {
+		return false
+	}
+	if canManageAsset(user, asset) {
+		return true
+	}
+
+	userID, err := strconv.ParseUint(user.ID, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	var count int64
+	if err := activeGrants(userID, user.Groups).Where("asset_id = ?", asset.ID).Count(&count).Error; err != nil {
+		return false
+	}
+
+	return count > 0
+}
+
+// canManageAsset indica se o usuário pode gerenciar o asset (dono ou admin
+// do mesmo tenant)
+func canManageAsset(user auth.UserInfo, asset models.Asset) bool {
+	if !sameTenant(user, asset.TenantID) {
+		return false
+	}
+	if user.Role == models.RoleAdmin || user.Role == models.RoleSuperAdmin {
+		return true
+	}
+
+	return asset.OwnerID != 0 && user.ID == strconv.FormatUint(uint64(asset.OwnerID), 10)
+}
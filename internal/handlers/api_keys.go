@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func CreateAPIKey(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome e escopos são obrigatórios"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Escopo inválido: " + scope})
+			return
+		}
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data de expiração já passou"})
+		return
+	}
+
+	key, prefix, hash, err := auth.GenerateAPIKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar API key"})
+		return
+	}
+
+	userID, _ := strconv.ParseUint(user.ID, 10, 64)
+	apiKey := models.APIKey{
+		UserID:    uint(userID),
+		Name:      strings.TrimSpace(req.Name),
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    strings.Join(req.Scopes, ","),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := database.DB.Create(&apiKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar API key"})
+		return
+	}
+
+	// A chave completa só é exibida nesta resposta
+	c.JSON(http.StatusCreated, gin.H{
+		"key":     key,
+		"api_key": apiKey,
+	})
+}
+
+func ListAPIKeys(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var keys []models.APIKey
+	if err := database.DB.Where("user_id = ?", user.ID).Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+func RevokeAPIKey(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var apiKey models.APIKey
+	if err := database.DB.First(&apiKey, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key não encontrada"})
+		return
+	}
+
+	if strconv.FormatUint(uint64(apiKey.UserID), 10) != user.ID {
+		var owner models.User
+		isAdmin := user.Role == models.RoleAdmin || user.Role == models.RoleSuperAdmin
+		if !isAdmin || database.DB.First(&owner, apiKey.UserID).Error != nil || !sameTenant(user, owner.TenantID) {
+			auth.Forbidden(c)
+			return
+		}
+	}
+
+	if err := database.DB.Delete(&apiKey).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao revogar API key"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
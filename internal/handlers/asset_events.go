@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sseKeepAlive é o intervalo dos comentários enviados para que proxies não
+// encerrem o stream ocioso
+const sseKeepAlive = 15 * time.Second
+
+// AssetEvents transmite por Server-Sent Events as mudanças de status e o
+// progresso dos jobs de processamento do usuário para o asset. O primeiro
+// evento é o estado atual; com ?version=N, apenas os jobs dessa versão.
+func AssetEvents(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var asset models.Asset
+	if err := database.DB.First(&asset, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arquivo não encontrado"})
+		return
+	}
+
+	if !canAccessAsset(user, asset) {
+		auth.Forbidden(c)
+		return
+	}
+
+	version := 0
+	if raw := c.Query("version"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+			return
+		}
+		version = parsed
+	}
+
+	ctx := c.Request.Context()
+	assetID := strconv.FormatUint(uint64(asset.ID), 10)
+
+	// A assinatura vem antes do estado atual para nenhum evento se perder
+	pubsub, err := redisQueue.SubscribeAssetEvents(ctx, assetID, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao acompanhar processamento"})
+		return
+	}
+	defer pubsub.Close()
+
+	// O WriteTimeout do servidor encerraria o stream
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Erro ao remover prazo de escrita do stream de eventos: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	if current, ok := currentJobEvent(asset, user, version); ok {
+		c.SSEvent(current.Type, current)
+	}
+	c.Writer.Flush()
+
+	messages := pubsub.Channel()
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-keepAlive.C:
+			io.WriteString(w, ": keep-alive\n\n")
+			return true
+		case msg, ok := <-messages:
+			if !ok {
+				return false
+			}
+			var event queue.JobEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("Evento de processamento inválido: %v", err)
+				return true
+			}
+			if version == 0 || event.AssetVersion == version {
+				c.SSEvent(event.Type, event)
+			}
+			return true
+		}
+	})
+}
+
+// currentJobEvent monta o evento com o estado do processamento mais recente
+// do usuário para o asset, se houver
+func currentJobEvent(asset models.Asset, user auth.UserInfo, version int) (queue.JobEvent, bool) {
+	query := database.DB.Where("asset_id = ? AND user_id = ? AND tenant_id = ?", asset.ID, user.ID, asset.TenantID)
+	if version != 0 {
+		query = query.Where("asset_version = ?", version)
+	}
+
+	var processed models.ProcessedAsset
+	if err := query.Order("asset_version DESC").Limit(1).Find(&processed).Error; err != nil || processed.ID == 0 {
+		return queue.JobEvent{}, false
+	}
+
+	event := queue.JobEvent{
+		Type:         processed.Status,
+		JobID:        processed.JobID,
+		AssetID:      strconv.FormatUint(uint64(asset.ID), 10),
+		UserID:       user.ID,
+		AssetVersion: processed.AssetVersion,
+		Error:        processed.ErrorMsg,
+		At:           processed.UpdatedAt,
+	}
+	switch {
+	case processed.Status == "completed":
+		event.Progress = 100
+	case processed.JobID != "":
+		if state, err := redisQueue.GetJob(processed.JobID); err == nil {
+			event.Progress = state.Progress
+		}
+	}
+	return event, true
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"net/http"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limites dos metadados editáveis
+const (
+	maxTags           = 50
+	maxTagLength      = 50
+	maxMetadataKeys   = 50
+	maxMetadataKey    = 64
+	maxMetadataValue  = 1024
+	maxTitleLength    = 255
+	maxAuthorLength   = 255
+	maxDescriptionLen = 10000
+)
+
+// UpdateAssetRequest traz apenas os campos a alterar. Tags e metadata, quando
+// enviados, substituem os valores anteriores.
+type UpdateAssetRequest struct {
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	Author      *string            `json:"author"`
+	Tags        *[]string          `json:"tags"`
+	Metadata    *map[string]string `json:"metadata"`
+}
+
+func UpdateAsset(c *gin.Context) {
+	asset, _, ok := loadManagedAsset(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateAssetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	// Apenas as colunas enviadas são gravadas
+	var columns []string
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if utf8.RuneCountInString(title) > maxTitleLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Título muito longo"})
+			return
+		}
+		asset.Title = title
+		columns = append(columns, "title")
+	}
+	if req.Description != nil {
+		if utf8.RuneCountInString(*req.Description) > maxDescriptionLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Descrição muito longa"})
+			return
+		}
+		asset.Description = *req.Description
+		columns = append(columns, "description")
+	}
+	if req.Author != nil {
+		author := strings.TrimSpace(*req.Author)
+		if utf8.RuneCountInString(author) > maxAuthorLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Autor muito longo"})
+			return
+		}
+		asset.Author = author
+		columns = append(columns, "author")
+	}
+	if req.Metadata != nil {
+		if !validMetadata(*req.Metadata) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Metadados inválidos"})
+			return
+		}
+		asset.Metadata = *req.Metadata
+		columns = append(columns, "metadata")
+	}
+
+	var tags []string
+	if req.Tags != nil {
+		var valid bool
+		tags, valid = normalizeTags(*req.Tags)
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tags inválidas"})
+			return
+		}
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if len(columns) > 0 {
+			if err := tx.Model(&asset).Select(columns).Updates(&asset).Error; err != nil {
+				return err
+			}
+		}
+		if req.Tags != nil {
+			return setTags(tx, asset.ID, tags)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar asset"})
+		return
+	}
+
+	if err := database.DB.First(&asset, asset.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar asset"})
+		return
+	}
+	if err := attachTags(&asset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, asset)
+}
+
+// normalizeTags remove espaços, converte para minúsculas e elimina repetições
+func normalizeTags(raw []string) ([]string, bool) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, tag := range raw {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, false
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > maxTags {
+		return nil, false
+	}
+	sort.Strings(tags)
+	return tags, true
+}
+
+func validMetadata(metadata map[string]string) bool {
+	if len(metadata) > maxMetadataKeys {
+		return false
+	}
+	for key, value := range metadata {
+		if strings.TrimSpace(key) == "" || utf8.RuneCountInString(key) > maxMetadataKey {
+			return false
+		}
+		if utf8.RuneCountInString(value) > maxMetadataValue {
+			return false
+		}
+	}
+	return true
+}
+
+// setTags substitui as tags do asset
+func setTags(tx *gorm.DB, assetID uint, tags []string) error {
+	if err := tx.Where("asset_id = ?", assetID).Delete(&models.AssetTag{}).Error; err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	rows := make([]models.AssetTag, len(tags))
+	for i, tag := range tags {
+		rows[i] = models.AssetTag{AssetID: assetID, Tag: tag}
+	}
+	return tx.Create(&rows).Error
+}
+
+// attachTags preenche o campo Tags dos assets com uma única consulta
+func attachTags(assets ...*models.Asset) error {
+	if len(assets) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(assets))
+	byID := make(map[uint]*models.Asset, len(assets))
+	for i, asset := range assets {
+		ids[i] = asset.ID
+		byID[asset.ID] = asset
+		asset.Tags = []string{}
+	}
+
+	var rows []models.AssetTag
+	if err := database.DB.Where("asset_id IN ?", ids).Order("tag").Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if asset, ok := byID[row.AssetID]; ok {
+			asset.Tags = append(asset.Tags, row.Tag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/storage"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errVersionConflict = errors.New("outra versão foi enviada ao mesmo tempo")
+
+// UploadAssetVersion substitui o arquivo do asset por uma nova versão. A
+// versão atual é preservada em AssetVersion e as cópias com marca d'água
+// geradas a partir dela são invalidadas.
+func UploadAssetVersion(c *gin.Context) {
+	asset, user, ok := loadManagedAsset(c)
+	if !ok {
+		return
+	}
+
+	part, file, contentType, ok := readUploadPart(c)
+	if !ok {
+		return
+	}
+	defer part.Close()
+
+	// O processamento e a marca d'água dependem do tipo; uma versão não o altera
+	if contentType != asset.Type {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": "A nova versão deve ser do mesmo tipo do arquivo atual",
+			"type":  asset.Type,
+		})
+		return
+	}
+
+	// Como no upload, o Content-Length só antecipa a recusa; a cota vale para
+	// o tamanho gravado
+	if c.Request.ContentLength >= 0 && !checkUploadQuota(c, asset.TenantID, c.Request.ContentLength) {
+		return
+	}
+
+	ctx := c.Request.Context()
+	staged, size, checksum, ok := stageUpload(c, file, contentType, asset.TenantID)
+	if !ok {
+		return
+	}
+	if !checkUploadQuota(c, asset.TenantID, size) {
+		storage.Default.Delete(ctx, staged)
+		return
+	}
+
+	if checksum == asset.SHA256 {
+		storage.Default.Delete(ctx, staged)
+		c.JSON(http.StatusConflict, gin.H{"error": "O arquivo é idêntico à versão atual", "version": asset.Version})
+		return
+	}
+
+	contentType, err := validateUpload(ctx, asset.TenantID, staged, part.FileName(), contentType, size)
+	if err != nil {
+		storage.Default.Delete(ctx, staged)
+		respondValidationError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	blob, _, err := commitBlob(ctx, asset.TenantID, staged, checksum, size, contentType)
+	if err != nil {
+		storage.Default.Delete(ctx, staged)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo"})
+		return
+	}
+
+	previous := asset.Version
+	cachePaths, err := replaceAssetFile(&asset, blob, ownerID(user))
+	if err != nil {
+		releaseBlob(ctx, models.Asset{TenantID: blob.TenantID, SHA256: blob.SHA256, Path: blob.Path})
+		if errors.Is(err, errVersionConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Outra versão foi enviada ao mesmo tempo"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar nova versão"})
+		return
+	}
+
+	removeCachedCopies(ctx, cachePaths)
+
+	if err := enqueueAsset(&asset); err != nil {
+		respondAssetError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":          "Nova versão enviada com sucesso",
+		"asset_id":         asset.ID,
+		"version":          asset.Version,
+		"previous_version": previous,
+		"size":             asset.Size,
+		"sha256":           asset.SHA256,
+		"type":             asset.Type,
+		"status":           asset.Status,
+		"download_url":     fmt.Sprintf("/assets/%d/download", asset.ID),
+	})
+}
+
+// replaceAssetFile arquiva a versão atual e aponta o asset para o blob novo.
+// Retorna as cópias com marca d'água da versão substituída, cujos registros
+// foram removidos, para que os arquivos sejam apagados após o commit.
+func replaceAssetFile(asset *models.Asset, blob models.Blob, uploader uint) ([]string, error) {
+	now := time.Now()
+	archived := models.AssetVersion{
+		AssetID:    asset.ID,
+		Version:    currentVersion(*asset),
+		Path:       asset.Path,
+		Type:       asset.Type,
+		Size:       asset.Size,
+		SHA256:     asset.SHA256,
+		Status:     asset.Status,
+		UploadedBy: asset.OwnerID,
+		CreatedAt:  asset.CreatedAt,
+		ReplacedAt: &now,
+	}
+	if asset.VersionCreatedAt != nil {
+		archived.UploadedBy = asset.VersionUploadedBy
+		archived.CreatedAt = *asset.VersionCreatedAt
+	}
+
+	var cachePaths []string
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// A condição na versão impede que dois envios simultâneos arquivem a mesma versão
+		result := tx.Model(&models.Asset{}).
+			Where("id = ? AND version = ?", asset.ID, asset.Version).
+			Updates(map[string]interface{}{
+				"path":                blob.Path,
+				"type":                blob.ContentType,
+				"size":                blob.Size,
+				"sha256":              blob.SHA256,
+				"status":              models.StatusPending,
+				"version":             archived.Version + 1,
+				"version_uploaded_by": uploader,
+				"version_created_at":  now,
+				"scan_result":         "",
+				"scanned_at":          nil,
+				"released_by":         nil,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errVersionConflict
+		}
+
+		if err := tx.Create(&archived).Error; err != nil {
+			return err
+		}
+
+		var err error
+		cachePaths, err = invalidateProcessedAssets(tx, asset.ID, archived.Version)
+		if err != nil {
+			return err
+		}
+
+		// Os metadados técnicos são extraídos novamente pelo worker
+		return tx.Where("asset_id = ?", asset.ID).Delete(&models.TechnicalMetadata{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	asset.Path = blob.Path
+	asset.Type = blob.ContentType
+	asset.Size = blob.Size
+	asset.SHA256 = blob.SHA256
+	asset.Status = models.StatusPending
+	asset.Version = archived.Version + 1
+	asset.VersionUploadedBy = uploader
+	asset.VersionCreatedAt = &now
+	asset.ScanResult = ""
+	asset.ScannedAt = nil
+	asset.ReleasedBy = nil
+	return cachePaths, nil
+}
+
+// invalidateProcessedAssets remove os registros das cópias com marca d'água
+// geradas a partir da versão informada e retorna os seus arquivos
+func invalidateProcessedAssets(tx *gorm.DB, assetID uint, version int) ([]string, error) {
+	var processed []models.ProcessedAsset
+	if err := tx.Where("asset_id = ? AND asset_version = ?", assetID, version).Find(&processed).Error; err != nil {
+		return nil, err
+	}
+
+	var cachePaths []string
+	for _, p := range processed {
+		if p.CachePath != "" {
+			cachePaths = append(cachePaths, p.CachePath)
+		}
+	}
+
+	err := tx.Where("asset_id = ? AND asset_version = ?", assetID, version).Delete(&models.ProcessedAsset{}).Error
+	return cachePaths, err
+}
+
+// removeCachedCopies apaga cópias com marca d'água que não são mais usadas
+func removeCachedCopies(ctx context.Context, cachePaths []string) {
+	for _, key := range cachePaths {
+		if err := storage.Default.Delete(ctx, key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Erro ao remover cópia em cache %s: %v", key, err)
+		}
+	}
+}
+
+// currentVersion trata assets anteriores ao versionamento como versão 1
+func currentVersion(asset models.Asset) int {
+	if asset.Version == 0 {
+		return 1
+	}
+	return asset.Version
+}
+
+// ListAssetVersions lista a versão atual e as anteriores, da mais recente
+// para a mais antiga
+func ListAssetVersions(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var asset models.Asset
+	if err := database.DB.First(&asset, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset não encontrado"})
+		return
+	}
+
+	if !canAccessAsset(user, asset) {
+		auth.Forbidden(c)
+		return
+	}
+
+	var previous []models.AssetVersion
+	if err := database.DB.Where("asset_id = ?", asset.ID).Order("version DESC").Find(&previous).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar versões"})
+		return
+	}
+
+	current := models.AssetVersion{
+		AssetID:    asset.ID,
+		Version:    currentVersion(asset),
+		Path:       asset.Path,
+		Type:       asset.Type,
+		Size:       asset.Size,
+		SHA256:     asset.SHA256,
+		Status:     asset.Status,
+		UploadedBy: asset.OwnerID,
+		CreatedAt:  asset.CreatedAt,
+	}
+	if asset.VersionCreatedAt != nil {
+		current.UploadedBy = asset.VersionUploadedBy
+		current.CreatedAt = *asset.VersionCreatedAt
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"current_version": current.Version,
+		"versions":        append([]models.AssetVersion{current}, previous...),
+	})
+}
@@ -1,19 +1,102 @@
 package handlers
 
 import (
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/ratelimit"
+
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterRoutes(r *gin.Engine) {
-	r.GET("/assets", ListAssets)
-	r.GET("/assets/process-status", GetAllProcessStatus)
+	superadmin := auth.RequireRole(models.RoleSuperAdmin)
+	admin := auth.RequireRole(models.RoleAdmin)
+	uploader := auth.RequireRole(models.RoleUploader)
+	// Rotas de gerenciamento não aceitam API keys, apenas login interativo
+	interactive := auth.RejectAPIKeys()
+	// Rotas sensíveis exigem 2FA para os papéis definidos em MFA_REQUIRED_ROLES
+	mfa := auth.RequireMFA()
+	downloadLimit := ratelimit.Middleware(ratelimit.Download, rateLimitUserKey)
+	uploadLimit := ratelimit.Middleware(ratelimit.Upload, rateLimitUserKey)
+
+	r.POST("/auth/logout", interactive, auth.LogoutHandler)
+	r.POST("/auth/logout-all", interactive, auth.LogoutAllHandler)
+	r.POST("/auth/2fa/enroll", interactive, auth.EnrollTOTPHandler)
+	r.POST("/auth/2fa/confirm", interactive, auth.ConfirmTOTPHandler)
+	r.POST("/auth/2fa/recovery-codes", interactive, auth.RegenerateRecoveryCodesHandler)
+	r.POST("/auth/2fa/disable", interactive, auth.DisableTOTPHandler)
+
+	r.GET("/assets", auth.RequireScope(auth.ScopeRead), ListAssets)
+	r.GET("/assets/process-status", interactive, admin, mfa, GetAllProcessStatus)
+
+	r.GET("/assets/:id", auth.RequireScope(auth.ScopeRead), GetAsset)
+	r.PATCH("/assets/:id", auth.RequireScope(auth.ScopeUpload), uploader, mfa, UpdateAsset)
+	r.DELETE("/assets/:id", auth.RequireScope(auth.ScopeUpload), uploader, mfa, DeleteAsset)
+	r.GET("/assets/:id/status", auth.RequireScope(auth.ScopeDownload), CheckProcessingStatus)
+	r.GET("/assets/:id/events", auth.RequireScope(auth.ScopeDownload), AssetEvents)
+	r.POST("/assets/:id/download", auth.RequireScope(auth.ScopeDownload), downloadLimit, DownloadHandlerV2)
+	r.PUT("/assets/:id/file", auth.RequireScope(auth.ScopeUpload), uploader, mfa, uploadLimit, UploadAssetVersion)
+	r.GET("/assets/:id/versions", auth.RequireScope(auth.ScopeRead), ListAssetVersions)
+
+	r.GET("/assets/:id/grants", interactive, ListGrants)
+	r.POST("/assets/:id/grants", interactive, mfa, CreateGrant)
+	r.DELETE("/assets/:id/grants/:grantId", interactive, mfa, RevokeGrant)
+
+	r.POST("/upload", auth.RequireScope(auth.ScopeUpload), uploader, mfa, uploadLimit, UploadHandler)
+
+	// Upload resumível em partes; o limite de taxa vale apenas para a criação
+	r.POST("/uploads", auth.RequireScope(auth.ScopeUpload), uploader, mfa, uploadLimit, CreateUploadHandler)
+	r.HEAD("/uploads/:id", auth.RequireScope(auth.ScopeUpload), UploadStatusHandler)
+	r.GET("/uploads/:id", auth.RequireScope(auth.ScopeUpload), GetUploadHandler)
+	r.PATCH("/uploads/:id", auth.RequireScope(auth.ScopeUpload), uploader, mfa, PatchUploadHandler)
+	r.DELETE("/uploads/:id", auth.RequireScope(auth.ScopeUpload), DeleteUploadHandler)
+
+	// Lixeira: assets apagados podem ser restaurados até o expurgo
+	r.GET("/trash", auth.RequireScope(auth.ScopeUpload), ListTrash)
+	r.POST("/trash/:id/restore", auth.RequireScope(auth.ScopeUpload), uploader, mfa, RestoreAsset)
+	r.DELETE("/trash/:id", auth.RequireScope(auth.ScopeUpload), uploader, mfa, PurgeTrashedAsset)
 
-	r.GET("/assets/:id", GetAsset)
-	r.GET("/assets/:id/status", CheckProcessingStatus)
-	r.POST("/assets/:id/download", DownloadHandlerV2)
+	r.GET("/quarantine", interactive, admin, mfa, ListQuarantine)
+	r.POST("/quarantine/:id/release", interactive, admin, mfa, ReleaseQuarantined)
+	r.DELETE("/quarantine/:id", interactive, admin, mfa, DeleteQuarantined)
+
+	r.GET("/jobs/:id", auth.RequireScope(auth.ScopeDownload), GetJob)
+
+	// Jobs de processamento que esgotaram as tentativas
+	r.GET("/jobs/dead", interactive, admin, mfa, ListDeadJobs)
+	r.POST("/jobs/dead/:id/requeue", interactive, admin, mfa, RequeueDeadJob)
+	r.DELETE("/jobs/dead/:id", interactive, admin, mfa, DiscardDeadJob)
+
+	r.GET("/api-keys", interactive, ListAPIKeys)
+	r.POST("/api-keys", interactive, mfa, CreateAPIKey)
+	r.DELETE("/api-keys/:id", interactive, RevokeAPIKey)
+
+	r.GET("/webhooks", interactive, ListWebhooks)
+	r.POST("/webhooks", interactive, mfa, CreateWebhook)
+	r.PATCH("/webhooks/:id", interactive, mfa, UpdateWebhook)
+	r.DELETE("/webhooks/:id", interactive, mfa, DeleteWebhook)
+	r.GET("/webhooks/:id/deliveries", interactive, ListWebhookDeliveries)
+
+	r.GET("/users", interactive, admin, mfa, ListUsers)
+	r.PATCH("/users/:id/role", interactive, admin, mfa, UpdateUserRole)
+	r.POST("/users/:id/revoke-sessions", interactive, admin, mfa, RevokeUserSessions)
+	r.POST("/users/:id/groups", interactive, admin, mfa, AddUserToGroup)
+	r.DELETE("/users/:id/groups/:group", interactive, admin, mfa, RemoveUserFromGroup)
+
+	r.GET("/tenants", interactive, superadmin, mfa, ListTenants)
+	r.POST("/tenants", interactive, superadmin, mfa, CreateTenant)
+	r.PATCH("/tenants/:id", interactive, superadmin, mfa, UpdateTenant)
+	r.PUT("/tenants/:id/users/:userId", interactive, superadmin, mfa, AssignUserToTenant)
 
-	r.POST("/upload", UploadHandler)
 	r.GET("/im-alive", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "I'm alive"})
 	})
 }
+
+// rateLimitUserKey identifica o bucket pelo usuário autenticado
+func rateLimitUserKey(c *gin.Context) string {
+	if userRaw, exists := c.Get("user"); exists {
+		return "user:" + userRaw.(auth.UserInfo).ID
+	}
+	return "ip:" + c.ClientIP()
+}
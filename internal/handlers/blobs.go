@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/storage"
+	"projeto_drm/poc/internal/validation"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// stagingKey é onde o upload é gravado enquanto o SHA-256 ainda não é conhecido
+func stagingKey(tenantID uint) string {
+	return path.Join("incoming", fmt.Sprintf("%d", tenantID), uuid.New().String())
+}
+
+// blobKey é a chave endereçada pelo conteúdo, isolada por tenant. A extensão
+// é mantida porque os workers escolhem o processamento pela extensão.
+func blobKey(tenantID uint, checksum, contentType string) string {
+	return path.Join("blobs", fmt.Sprintf("%d", tenantID), checksum[:2], checksum+validation.Extension(contentType))
+}
+
+// commitBlob transforma o upload em staged em um blob do tenant. Se o tenant
+// já tem o mesmo conteúdo, a cópia recebida é descartada e o blob existente
+// ganha mais uma referência. Retorna o blob e se houve deduplicação.
+//
+// A linha e o objeto são criados na mesma transação: enquanto ela não termina,
+// um releaseBlob concorrente do mesmo conteúdo espera pela linha, e vice-versa,
+// então o objeto gravado aqui nunca é apagado por uma remoção em andamento.
+func commitBlob(ctx context.Context, tenantID uint, staged, checksum string, size int64, contentType string) (models.Blob, bool, error) {
+	var blob models.Blob
+	deduplicated := false
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		blob = models.Blob{
+			TenantID:    tenantID,
+			SHA256:      checksum,
+			Path:        blobKey(tenantID, checksum, contentType),
+			Size:        size,
+			ContentType: contentType,
+			RefCount:    1,
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "tenant_id"}, {Name: "sha256"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1")}),
+		}).Create(&blob).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("tenant_id = ? AND sha256 = ?", tenantID, checksum).First(&blob).Error; err != nil {
+			return err
+		}
+
+		// Com mais de uma referência o conteúdo já está no armazenamento
+		if blob.RefCount > 1 {
+			deduplicated = true
+			return nil
+		}
+		return storage.Default.Move(ctx, staged, blob.Path)
+	})
+	if err != nil {
+		return models.Blob{}, false, err
+	}
+
+	if deduplicated {
+		storage.Default.Delete(ctx, staged)
+	}
+	return blob, deduplicated, nil
+}
+
+// releaseBlob remove a referência do asset ao seu conteúdo, apagando o objeto
+// quando ninguém mais o usa; removed indica se o objeto foi apagado. Assets
+// anteriores à deduplicação não têm blob e o arquivo é apagado diretamente.
+//
+// O decremento, a remoção da linha e a do objeto acontecem na mesma transação,
+// para que um commitBlob concorrente não reaproveite um blob sendo apagado.
+func releaseBlob(ctx context.Context, asset models.Asset) (removed bool, err error) {
+	legacy := false
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var blob models.Blob
+		err := tx.Where("tenant_id = ? AND sha256 = ? AND path = ?", asset.TenantID, asset.SHA256, asset.Path).First(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			legacy = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Model(&blob).UpdateColumn("ref_count", gorm.Expr("ref_count - 1")).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("id = ? AND ref_count = 0", blob.ID).Delete(&models.Blob{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		removed = true
+		return storage.Default.Delete(ctx, blob.Path)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	if legacy {
+		return true, storage.Default.Delete(ctx, asset.Path)
+	}
+	return removed, nil
+}
+
+// Políticas para nomes de arquivo repetidos dentro do tenant, definidas por
+// UPLOAD_NAME_POLICY
+const (
+	namePolicyRename = "rename" // acrescenta " (1)", " (2)"... ao nome
+	namePolicyAllow  = "allow"  // aceita nomes repetidos
+	namePolicyReject = "reject" // recusa o upload com 409
+)
+
+var errNameTaken = errors.New("nome de arquivo já existe")
+
+func namePolicy() string {
+	switch policy := os.Getenv("UPLOAD_NAME_POLICY"); policy {
+	case namePolicyAllow, namePolicyReject:
+		return policy
+	default:
+		return namePolicyRename
+	}
+}
+
+// resolveAssetName aplica a política de nomes ao nome enviado, retornando o
+// nome final do asset ou errNameTaken
+func resolveAssetName(tenantID uint, name string) (string, error) {
+	policy := namePolicy()
+	if policy == namePolicyAllow {
+		return name, nil
+	}
+
+	taken, err := assetNameExists(tenantID, name)
+	if err != nil || !taken {
+		return name, err
+	}
+	if policy == namePolicyReject {
+		return "", errNameTaken
+	}
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; i <= 1000; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		taken, err := assetNameExists(tenantID, candidate)
+		if err != nil {
+			return "", err
+		}
+		if !taken {
+			return candidate, nil
+		}
+	}
+	return "", errNameTaken
+}
+
+func assetNameExists(tenantID uint, name string) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.Asset{}).Where("name = ? AND tenant_id = ?", name, tenantID).Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDeadJobs lista os jobs de processamento do tenant que esgotaram as
+// tentativas ou falharam de forma permanente
+func ListDeadJobs(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	jobs, err := redisQueue.ListDeadJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar jobs mortos"})
+		return
+	}
+
+	visible := make([]queue.DeadJob, 0, len(jobs))
+	for _, dead := range jobs {
+		if sameTenant(user, dead.Job.TenantID) {
+			visible = append(visible, dead)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"jobs": visible, "total": len(visible)})
+}
+
+// RequeueDeadJob devolve um job morto à fila com as tentativas zeradas
+func RequeueDeadJob(c *gin.Context) {
+	dead, user, ok := loadDeadJob(c)
+	if !ok {
+		return
+	}
+
+	job, err := redisQueue.RequeueDeadJob(dead.Job.ID)
+	if errors.Is(err, queue.ErrDeadJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao reenfileirar job"})
+		return
+	}
+
+	// Sem isso o próximo download criaria outro job para o mesmo arquivo
+	err = database.DB.Model(&models.ProcessedAsset{}).
+		Where("asset_id = ? AND user_id = ? AND tenant_id = ? AND asset_version = ? AND status = ?",
+			job.AssetID, job.UserID, job.TenantID, max(job.AssetVersion, 1), "failed").
+		Updates(map[string]interface{}{"status": "queued", "error_msg": ""}).Error
+	if err != nil {
+		log.Printf("Erro ao atualizar processamento do job %s: %v", job.ID, err)
+	}
+
+	log.Printf("Job %s reenfileirado por %s (erro anterior: %s)", job.ID, user.Email, dead.Error)
+	c.JSON(http.StatusOK, gin.H{"message": "Job reenfileirado", "job": job})
+}
+
+// DiscardDeadJob remove definitivamente um job morto
+func DiscardDeadJob(c *gin.Context) {
+	dead, user, ok := loadDeadJob(c)
+	if !ok {
+		return
+	}
+
+	err := redisQueue.DiscardDeadJob(dead.Job.ID)
+	if errors.Is(err, queue.ErrDeadJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao descartar job"})
+		return
+	}
+
+	log.Printf("Job %s descartado por %s", dead.Job.ID, user.Email)
+	c.JSON(http.StatusOK, gin.H{"message": "Job descartado"})
+}
+
+// loadDeadJob busca o job morto da rota e confere se é do tenant do usuário
+func loadDeadJob(c *gin.Context) (queue.DeadJob, auth.UserInfo, bool) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return queue.DeadJob{}, auth.UserInfo{}, false
+	}
+	user := userRaw.(auth.UserInfo)
+
+	dead, err := redisQueue.GetDeadJob(c.Param("id"))
+	if errors.Is(err, queue.ErrDeadJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return dead, user, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar job"})
+		return dead, user, false
+	}
+
+	// Jobs de outros tenants são tratados como inexistentes
+	if !sameTenant(user, dead.Job.TenantID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return dead, user, false
+	}
+
+	return dead, user, true
+}
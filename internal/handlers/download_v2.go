@@ -1,17 +1,20 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"log"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"projeto_drm/poc/internal/auth"
 	"projeto_drm/poc/internal/database"
 	"projeto_drm/poc/internal/models"
 	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
 	"strconv"
 	"time"
 )
@@ -37,8 +40,39 @@ func DownloadHandlerV2(c *gin.Context) {
 		return
 	}
 
+	if !canAccessAsset(user, asset) {
+		auth.Forbidden(c)
+		return
+	}
+
+	// ?version=N baixa uma versão anterior; sem ele, a atual
+	version := currentVersion(asset)
+	assetPath := asset.Path
+	status := asset.Status
+	if raw := c.Query("version"); raw != "" {
+		requested, err := strconv.Atoi(raw)
+		if err != nil || requested < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Versão inválida"})
+			return
+		}
+		if requested != version {
+			var previous models.AssetVersion
+			if err := database.DB.Where("asset_id = ? AND version = ?", asset.ID, requested).First(&previous).Error; err != nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Versão não encontrada"})
+				return
+			}
+			version, assetPath, status = previous.Version, previous.Path, previous.Status
+		}
+	}
+
+	// Só versões já verificadas pelo pós-processamento podem ser baixadas
+	if status != models.StatusCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Arquivo indisponível para download", "status": status})
+		return
+	}
+
 	// Validar extensão
-	ext := filepath.Ext(asset.Path)
+	ext := filepath.Ext(assetPath)
 	allowedExtensions := []string{".pdf", ".mp4", ".mov"}
 	isValidExtension := false
 	for _, allowedExt := range allowedExtensions {
@@ -64,16 +98,16 @@ func DownloadHandlerV2(c *gin.Context) {
 	}
 
 	var processedAsset models.ProcessedAsset
-	erro := database.DB.Where("asset_id = ? AND user_id = ?", assetIDUint, userIDUint).First(&processedAsset).Error
+	erro := database.DB.Where("asset_id = ? AND user_id = ? AND asset_version = ?", assetIDUint, userIDUint, version).
+		First(&processedAsset).Error
 
 	if erro == nil {
 		// Já existe registro
 		switch processedAsset.Status {
 		case "completed":
 			// Verificar se arquivo ainda existe no cache
-			if _, err := os.Stat(processedAsset.CachePath); err == nil {
-				filename := filepath.Base(asset.Path)
-				c.FileAttachment(processedAsset.CachePath, filename)
+			if info, err := storage.Default.Stat(c.Request.Context(), processedAsset.CachePath); err == nil {
+				serveCachedFile(c, processedAsset.CachePath, path.Base(assetPath), info)
 				return
 			} else {
 				// Cache foi removido, reprocessar
@@ -86,14 +120,14 @@ func DownloadHandlerV2(c *gin.Context) {
 			c.JSON(http.StatusAccepted, gin.H{
 				"status":  "processing",
 				"message": "Arquivo sendo processado. Tente novamente em alguns instantes.",
-				"job_id":  fmt.Sprintf("%s_%s", assetID, user.ID),
+				"job_id":  processedAsset.JobID,
 			})
 			return
 		case "queued":
 			c.JSON(http.StatusAccepted, gin.H{
 				"status":  "queued",
 				"message": "Arquivo na fila de processamento. Tente novamente em alguns instantes.",
-				"job_id":  fmt.Sprintf("%s_%s", assetID, user.ID),
+				"job_id":  processedAsset.JobID,
 			})
 			return
 		case "failed":
@@ -105,9 +139,11 @@ func DownloadHandlerV2(c *gin.Context) {
 	} else {
 		// Criar novo registro
 		processedAsset = models.ProcessedAsset{
-			AssetID: uint(assetIDUint),
-			UserID:  uint(userIDUint),
-			Status:  "queued",
+			AssetID:      uint(assetIDUint),
+			UserID:       uint(userIDUint),
+			TenantID:     asset.TenantID,
+			AssetVersion: version,
+			Status:       "queued",
 		}
 		if err := database.DB.Create(&processedAsset).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar registro de processamento"})
@@ -118,24 +154,67 @@ func DownloadHandlerV2(c *gin.Context) {
 	// Enfileirar job
 	jobID := uuid.New().String()
 	job := queue.ProcessingJob{
-		ID:        jobID,
-		AssetID:   assetID,
-		UserID:    user.ID,
-		AssetPath: asset.Path,
-		AssetType: ext,
-		UserEmail: user.Email,
-		CreatedAt: time.Now(),
+		ID:           jobID,
+		AssetID:      assetID,
+		UserID:       user.ID,
+		AssetPath:    assetPath,
+		AssetType:    ext,
+		UserEmail:    user.Email,
+		TenantID:     asset.TenantID,
+		CreatedAt:    time.Now(),
+		AssetVersion: version,
+	}
+
+	// Registrado antes de enfileirar para o worker não sobrescrever o ID
+	if err := database.DB.Model(&processedAsset).Update("job_id", jobID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao registrar job de processamento"})
+		return
 	}
 
 	if err := redisQueue.EnqueueJob(job); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao enfileirar processamento"})
 		return
 	}
+	if err := redisQueue.PublishJobEvent(queue.NewJobEvent(&job, "queued")); err != nil {
+		log.Printf("Erro ao publicar evento do job %s: %v", jobID, err)
+	}
 
 	c.JSON(http.StatusAccepted, gin.H{
 		"status":  "queued",
 		"message": "Arquivo adicionado à fila de processamento. Tente novamente em alguns instantes.",
-		"job_id":  fmt.Sprintf("%s_%s", assetID, user.ID),
+		"job_id":  processedAsset.JobID,
+	})
+}
+
+// serveCachedFile entrega a cópia com marca d'água: redireciona para uma URL
+// assinada quando STORAGE_PRESIGN_DOWNLOADS=true e o backend suporta, ou
+// transmite o conteúdo pela própria API
+func serveCachedFile(c *gin.Context, key, filename string, info storage.ObjectInfo) {
+	if os.Getenv("STORAGE_PRESIGN_DOWNLOADS") == "true" {
+		url, err := storage.Default.PresignGet(c.Request.Context(), key, 15*time.Minute, filename)
+		if err == nil {
+			c.Redirect(http.StatusSeeOther, url)
+			return
+		}
+		if !errors.Is(err, storage.ErrPresignUnsupported) {
+			log.Printf("Erro ao gerar URL assinada para %s: %v", key, err)
+		}
+	}
+
+	reader, err := storage.Default.Get(c.Request.Context(), key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao ler arquivo processado"})
+		return
+	}
+	defer reader.Close()
+
+	contentType := info.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	c.DataFromReader(http.StatusOK, info.Size, contentType, reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", filename),
 	})
 }
 
@@ -151,8 +230,14 @@ func CheckProcessingStatus(c *gin.Context) {
 	assetID := c.Param("id")
 
 	log.Println("Buscando status do processamento para assetID:", assetID, "e userID:", user.ID)
+	// Sem ?version=N, vale a versão mais recente já solicitada
+	query := database.DB.Where("asset_id = ? AND user_id = ? AND tenant_id = ?", assetID, user.ID, user.TenantID)
+	if version := c.Query("version"); version != "" {
+		query = query.Where("asset_version = ?", version)
+	}
+
 	var processedAsset models.ProcessedAsset
-	err := database.DB.Where("asset_id = ? AND user_id = ?", assetID, user.ID).First(&processedAsset).Error
+	err := query.Order("asset_version DESC").First(&processedAsset).Error
 
 	if err != nil {
 		log.Println("Erro ao buscar status do processamento:", err)
@@ -162,6 +247,8 @@ func CheckProcessingStatus(c *gin.Context) {
 
 	response := gin.H{
 		"asset_id":     assetID,
+		"job_id":       processedAsset.JobID,
+		"version":      processedAsset.AssetVersion,
 		"status":       processedAsset.Status,
 		"processed_at": processedAsset.ProcessedAt,
 	}
@@ -180,10 +267,11 @@ func GetAllProcessStatus(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
 		return
 	}
+	user := userRaw.(auth.UserInfo)
 
-	//recupera os status da tabela processed_assets, todos eles, faz um select * from
+	//recupera os status da tabela processed_assets do tenant do usuário
 	var processedAssets []models.ProcessedAsset
-	err := database.DB.Find(&processedAssets).Error
+	err := tenantScope(database.DB, user, "processed_assets").Find(&processedAssets).Error
 
 	if err != nil {
 		log.Println("Erro ao buscar status do processamento:", err)
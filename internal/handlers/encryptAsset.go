@@ -70,9 +70,9 @@ func DownloadHandler(c *gin.Context) {
 	var err error
 	switch ext {
 	case ".pdf":
-		err = watermarker.AddPDFWatermark(asset.Path, outputPath, fmt.Sprintf("%s (%s)", user.ID, user.Email))
+		err = watermarker.AddPDFWatermark(asset.Path, outputPath, fmt.Sprintf("%s (%s)", user.ID, user.Email), nil)
 	case ".mp4", ".mov":
-		err = watermarker.AddVideoWatermark(asset.Path, outputPath, fmt.Sprintf("%s (%s)", user.ID, user.Email))
+		err = watermarker.AddVideoWatermark(asset.Path, outputPath, fmt.Sprintf("%s (%s)", user.ID, user.Email), nil)
 	default:
 		// Arquivo sem watermarking
 		outputPath = asset.Path
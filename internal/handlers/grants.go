@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateGrantRequest struct {
+	UserID    *uint      `json:"user_id"`
+	Group     string     `json:"group"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// loadManagedAsset busca o asset da rota e verifica se o usuário pode gerenciá-lo
+func loadManagedAsset(c *gin.Context) (models.Asset, auth.UserInfo, bool) {
+	var asset models.Asset
+
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return asset, auth.UserInfo{}, false
+	}
+	user := userRaw.(auth.UserInfo)
+
+	if err := database.DB.First(&asset, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset não encontrado"})
+		return asset, user, false
+	}
+
+	if !canManageAsset(user, asset) {
+		auth.Forbidden(c)
+		return asset, user, false
+	}
+
+	return asset, user, true
+}
+
+func ListGrants(c *gin.Context) {
+	asset, _, ok := loadManagedAsset(c)
+	if !ok {
+		return
+	}
+
+	var grants []models.AssetGrant
+	if err := database.DB.Where("asset_id = ?", asset.ID).Find(&grants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar permissões"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"grants": grants})
+}
+
+func CreateGrant(c *gin.Context) {
+	asset, user, ok := loadManagedAsset(c)
+	if !ok {
+		return
+	}
+
+	var req CreateGrantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	group := strings.TrimSpace(req.Group)
+	if (req.UserID == nil) == (group == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe user_id ou group"})
+		return
+	}
+	if req.ExpiresAt != nil && req.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data de expiração já passou"})
+		return
+	}
+
+	if req.UserID != nil {
+		var target models.User
+		if err := database.DB.Where("tenant_id = ?", asset.TenantID).First(&target, *req.UserID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+			return
+		}
+	}
+
+	grantedBy, _ := strconv.ParseUint(user.ID, 10, 64)
+	grant := models.AssetGrant{
+		AssetID:   asset.ID,
+		UserID:    req.UserID,
+		GroupName: group,
+		ExpiresAt: req.ExpiresAt,
+		GrantedBy: uint(grantedBy),
+	}
+
+	if err := database.DB.Create(&grant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao conceder acesso"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, grant)
+}
+
+func RevokeGrant(c *gin.Context) {
+	asset, _, ok := loadManagedAsset(c)
+	if !ok {
+		return
+	}
+
+	var grant models.AssetGrant
+	err := database.DB.Where("id = ? AND asset_id = ?", c.Param("grantId"), asset.ID).First(&grant).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permissão não encontrada"})
+		return
+	}
+
+	if err := database.DB.Delete(&grant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao revogar acesso"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
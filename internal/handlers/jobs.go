@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetJob retorna o status, as tentativas e o progresso de um job de
+// processamento. O job_id vem da resposta de POST /assets/:id/download.
+func GetJob(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	jobID := c.Param("id")
+	state, err := redisQueue.GetJob(jobID)
+	if errors.Is(err, queue.ErrJobNotFound) {
+		// O estado no Redis expira; o resultado continua no processamento
+		state, err = jobStateFromProcessedAsset(jobID)
+	}
+	if errors.Is(err, queue.ErrJobNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar job"})
+		return
+	}
+
+	// Jobs de outros usuários são tratados como inexistentes
+	isAdmin := user.Role == models.RoleAdmin || user.Role == models.RoleSuperAdmin
+	if !sameTenant(user, state.TenantID) || (state.UserID != user.ID && !isAdmin) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job não encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, state)
+}
+
+// jobStateFromProcessedAsset monta o estado de um job cujo registro no Redis
+// já expirou
+func jobStateFromProcessedAsset(jobID string) (queue.JobState, error) {
+	var processed models.ProcessedAsset
+	if err := database.DB.Where("job_id = ?", jobID).Limit(1).Find(&processed).Error; err != nil {
+		return queue.JobState{}, err
+	}
+	if processed.ID == 0 {
+		return queue.JobState{}, queue.ErrJobNotFound
+	}
+
+	state := queue.JobState{
+		ID:           jobID,
+		AssetID:      strconv.FormatUint(uint64(processed.AssetID), 10),
+		UserID:       strconv.FormatUint(uint64(processed.UserID), 10),
+		TenantID:     processed.TenantID,
+		AssetVersion: processed.AssetVersion,
+		Status:       processed.Status,
+		MaxAttempts:  redisQueue.MaxAttempts,
+		Error:        processed.ErrorMsg,
+		CreatedAt:    &processed.CreatedAt,
+		UpdatedAt:    &processed.UpdatedAt,
+		FinishedAt:   processed.ProcessedAt,
+	}
+	if processed.Status == "completed" {
+		state.Progress = 100
+	}
+	return state, nil
+}
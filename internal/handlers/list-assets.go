@@ -1,33 +1,246 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"projeto_drm/poc/internal/auth"
 	"projeto_drm/poc/internal/database"
 	"projeto_drm/poc/internal/models"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type ListAssetsResponse struct {
-	Assets []models.Asset `json:"assets"`
+	Assets     []models.Asset `json:"assets"`
+	NextCursor string         `json:"next_cursor,omitempty"`
 }
 
+const (
+	defaultListLimit = 50
+	maxListLimit     = 200
+)
+
+// sortColumns são as ordenações aceitas em ?sort=
+var sortColumns = map[string]string{
+	"created_at": "assets.created_at",
+	"name":       "assets.name",
+	"title":      "assets.title",
+	"size":       "assets.size",
+}
+
+// listCursor é a posição do último asset da página: o valor da coluna de
+// ordenação e o ID, que desempata valores iguais
+type listCursor struct {
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// ListAssets aceita os filtros type, status, tag (repetível; o asset precisa
+// ter todas), created_after, created_before e q (busca em nome e título), a
+// ordenação sort/order e a paginação limit/cursor
 func ListAssets(c *gin.Context) {
-	var assets []models.Asset
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
 
-	if err := database.DB.Find(&assets).Error; err != nil {
+	query, err := filterAssets(visibleAssets(database.DB.Model(&models.Asset{}), user), c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "created_at")
+	column, ok := sortColumns[sortBy]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ordenação inválida"})
+		return
+	}
+	order := strings.ToLower(c.DefaultQuery("order", "desc"))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ordem inválida"})
+		return
+	}
+
+	limit := defaultListLimit
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxListLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Limite inválido"})
+			return
+		}
+	}
+
+	if raw := c.Query("cursor"); raw != "" {
+		value, id, err := decodeCursor(raw, sortBy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Cursor inválido"})
+			return
+		}
+		op := "<"
+		if order == "asc" {
+			op = ">"
+		}
+		query = query.Where("("+column+" "+op+" ?) OR ("+column+" = ? AND assets.id "+op+" ?)", value, value, id)
+	}
+
+	// Um registro a mais indica se existe próxima página
+	var assets []models.Asset
+	err = query.Order(column + " " + order).Order("assets.id " + order).Limit(limit + 1).Find(&assets).Error
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "Failed to retrieve assets",
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, ListAssetsResponse{
-		Assets: assets,
-	})
+	response := ListAssetsResponse{Assets: assets}
+	if len(assets) > limit {
+		response.Assets = assets[:limit]
+		response.NextCursor, err = encodeCursor(response.Assets[limit-1], sortBy)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve assets"})
+			return
+		}
+	}
+
+	pointers := make([]*models.Asset, len(response.Assets))
+	for i := range response.Assets {
+		pointers[i] = &response.Assets[i]
+	}
+	if err := attachTags(pointers...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// filterAssets aplica os filtros da query string
+func filterAssets(query *gorm.DB, c *gin.Context) (*gorm.DB, error) {
+	if assetType := c.Query("type"); assetType != "" {
+		query = query.Where("assets.type = ?", assetType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("assets.status = ?", status)
+	}
+
+	if raw := c.QueryArray("tag"); len(raw) > 0 {
+		tags, ok := normalizeTags(raw)
+		if !ok {
+			return nil, errors.New("Tag inválida")
+		}
+		tagged := database.DB.Model(&models.AssetTag{}).
+			Select("asset_id").
+			Where("tag IN ?", tags).
+			Group("asset_id").
+			Having("COUNT(DISTINCT tag) = ?", len(tags))
+		query = query.Where("assets.id IN (?)", tagged)
+	}
+
+	if raw := c.Query("created_after"); raw != "" {
+		after, err := parseDateParam(raw)
+		if err != nil {
+			return nil, errors.New("Data inválida em created_after")
+		}
+		query = query.Where("assets.created_at >= ?", after)
+	}
+	if raw := c.Query("created_before"); raw != "" {
+		before, err := parseDateParam(raw)
+		if err != nil {
+			return nil, errors.New("Data inválida em created_before")
+		}
+		query = query.Where("assets.created_at < ?", before)
+	}
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		pattern := "%" + escapeLike(strings.ToLower(q)) + "%"
+		query = query.Where(`LOWER(assets.name) LIKE ? ESCAPE '\' OR LOWER(assets.title) LIKE ? ESCAPE '\'`, pattern, pattern)
+	}
+
+	return query, nil
+}
+
+// parseDateParam aceita RFC 3339 ou apenas a data (AAAA-MM-DD, em UTC)
+func parseDateParam(raw string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", raw)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func encodeCursor(asset models.Asset, sortBy string) (string, error) {
+	var value interface{}
+	switch sortBy {
+	case "name":
+		value = asset.Name
+	case "title":
+		value = asset.Title
+	case "size":
+		value = asset.Size
+	default:
+		value = asset.CreatedAt
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(listCursor{Value: raw, ID: asset.ID})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor retorna o valor tipado conforme a coluna de ordenação; um
+// cursor gerado para outra ordenação é recusado
+func decodeCursor(raw, sortBy string) (interface{}, uint, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, 0, err
+	}
+	var cursor listCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, 0, err
+	}
+
+	switch sortBy {
+	case "name", "title":
+		var value string
+		err = json.Unmarshal(cursor.Value, &value)
+		return value, cursor.ID, err
+	case "size":
+		var value int64
+		err = json.Unmarshal(cursor.Value, &value)
+		return value, cursor.ID, err
+	default:
+		var value time.Time
+		err = json.Unmarshal(cursor.Value, &value)
+		return value, cursor.ID, err
+	}
 }
 
 func GetAsset(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
 	assetID := c.Param("id")
 	var asset models.Asset
 
@@ -38,5 +251,26 @@ func GetAsset(c *gin.Context) {
 		return
 	}
 
+	if !canAccessAsset(user, asset) {
+		auth.Forbidden(c)
+		return
+	}
+
+	if err := attachTags(&asset); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar tags"})
+		return
+	}
+
+	// Assets ainda em processamento (ou de tipos sem extração) não têm metadados técnicos
+	var technical models.TechnicalMetadata
+	result := database.DB.Where("asset_id = ?", asset.ID).Limit(1).Find(&technical)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar metadados técnicos"})
+		return
+	}
+	if result.RowsAffected > 0 {
+		asset.Technical = &technical
+	}
+
 	c.JSON(http.StatusOK, asset)
 }
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/extractor"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ListQuarantine lista os assets do tenant retidos pelo antivírus
+func ListQuarantine(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var assets []models.Asset
+	err := tenantScope(database.DB, user, "assets").
+		Where("status = ?", models.StatusQuarantined).
+		Order("scanned_at DESC").
+		Find(&assets).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar assets em quarentena"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assets": assets, "total": len(assets)})
+}
+
+// ReleaseQuarantined libera um asset em quarentena após revisão manual (por
+// exemplo, um falso positivo), tornando-o disponível para download
+func ReleaseQuarantined(c *gin.Context) {
+	asset, user, ok := loadQuarantined(c)
+	if !ok {
+		return
+	}
+
+	reviewer := ownerID(user)
+	err := database.DB.Model(&asset).Updates(map[string]interface{}{
+		"status":      models.StatusCompleted,
+		"released_by": reviewer,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao liberar asset"})
+		return
+	}
+
+	log.Printf("Asset %d liberado da quarentena por %s (assinatura: %s)", asset.ID, user.Email, asset.ScanResult)
+	asset.Status = models.StatusCompleted
+	asset.ReleasedBy = &reviewer
+
+	// Para quem acompanha por webhook, o upload só agora ficou disponível
+	webhook.EmitAsset(models.EventAssetUploaded, asset)
+
+	// O worker não extrai metadados de assets em quarentena
+	go func(asset models.Asset) {
+		if err := extractor.ExtractAsset(context.Background(), asset); err != nil {
+			log.Printf("Erro ao extrair metadados do asset %d: %v", asset.ID, err)
+		}
+	}(asset)
+
+	c.JSON(http.StatusOK, asset)
+}
+
+// DeleteQuarantined expurga definitivamente um asset em quarentena, sem
+// passar pela lixeira. O arquivo só é apagado se nenhum outro asset usar o
+// mesmo conteúdo.
+func DeleteQuarantined(c *gin.Context) {
+	asset, user, ok := loadQuarantined(c)
+	if !ok {
+		return
+	}
+
+	summary, err := purgeAsset(c.Request.Context(), asset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao apagar asset"})
+		return
+	}
+
+	log.Printf("Asset %d em quarentena apagado por %s (assinatura: %s)", asset.ID, user.Email, asset.ScanResult)
+	c.JSON(http.StatusOK, summary)
+}
+
+// loadQuarantined busca o asset da rota no tenant do usuário e confere se
+// está em quarentena
+func loadQuarantined(c *gin.Context) (models.Asset, auth.UserInfo, bool) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return models.Asset{}, auth.UserInfo{}, false
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var asset models.Asset
+	err := tenantScope(database.DB, user, "assets").First(&asset, c.Param("id")).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset não encontrado"})
+		return asset, user, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar asset"})
+		return asset, user, false
+	}
+
+	if asset.Status != models.StatusQuarantined {
+		c.JSON(http.StatusConflict, gin.H{"error": "Asset não está em quarentena", "status": asset.Status})
+		return asset, user, false
+	}
+
+	return asset, user, true
+}
@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"path/filepath"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
+	"projeto_drm/poc/internal/validation"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Upload resumível, inspirado no protocolo tus:
+//
+//	POST   /uploads      cria a sessão (nome, tipo, tamanho e SHA-256 esperados)
+//	PATCH  /uploads/:id  envia uma parte a partir de Upload-Offset
+//	HEAD   /uploads/:id  informa quantos bytes já foram recebidos
+//	DELETE /uploads/:id  cancela o upload
+//
+// Cada parte é gravada no backend de armazenamento. Quando o último byte
+// chega a sessão passa a completing e, em segundo plano, as partes são
+// concatenadas, o SHA-256 é conferido e só então o Asset é criado e o
+// AssetJob enfileirado. O cliente acompanha a conclusão pelo GET.
+const (
+	maxResumableUploadSize = 10 << 30 // 10GB
+	maxChunkSize           = 64 << 20 // 64MB
+	uploadSessionTTL       = 24 * time.Hour
+	chunkContentType       = "application/offset+octet-stream"
+)
+
+type createUploadRequest struct {
+	Filename    string `json:"filename" binding:"required"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required"`
+	Checksum    string `json:"checksum" binding:"required"`
+}
+
+// CreateUploadHandler abre uma sessão de upload resumível
+func CreateUploadHandler(c *gin.Context) {
+	var req createUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Dados inválidos"})
+		return
+	}
+
+	if strings.Contains(req.Filename, "..") || filepath.Base(req.Filename) != req.Filename {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome de arquivo inválido"})
+		return
+	}
+	// O conteúdo só é conferido ao final; aqui valem o tipo declarado e a extensão
+	if !validation.Supported(req.ContentType) || validation.CheckType(req.Filename, req.ContentType, req.ContentType) != nil {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Tipo de arquivo não suportado"})
+		return
+	}
+	if req.Size <= 0 || req.Size > maxResumableUploadSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Tamanho inválido ou acima do limite de 10GB"})
+		return
+	}
+	checksum := strings.ToLower(req.Checksum)
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "checksum deve ser o SHA-256 do arquivo em hexadecimal"})
+		return
+	}
+
+	user := currentUploader(c)
+
+	withinQuota, err := checkTenantQuota(user.TenantID, req.Size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar cota de armazenamento"})
+		return
+	}
+	if !withinQuota {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Cota de armazenamento do tenant excedida"})
+		return
+	}
+
+	if _, err := resolveAssetName(user.TenantID, req.Filename); err != nil {
+		respondNameError(c, err)
+		return
+	}
+
+	session := models.UploadSession{
+		UploadID:    uuid.New().String(),
+		UserID:      ownerID(user),
+		TenantID:    user.TenantID,
+		Filename:    req.Filename,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		Checksum:    checksum,
+		Status:      models.UploadInProgress,
+		ExpiresAt:   time.Now().Add(uploadSessionTTL),
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar sessão de upload"})
+		return
+	}
+
+	setUploadHeaders(c, session)
+	c.Header("Location", "/uploads/"+session.UploadID)
+	c.JSON(http.StatusCreated, session)
+}
+
+// UploadStatusHandler responde ao HEAD com o offset atual, para que o
+// cliente saiba de onde retomar
+func UploadStatusHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	setUploadHeaders(c, session)
+	c.Status(http.StatusOK)
+}
+
+// GetUploadHandler retorna a sessão de upload em JSON
+func GetUploadHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	setUploadHeaders(c, session)
+	c.JSON(http.StatusOK, session)
+}
+
+// PatchUploadHandler grava uma parte do arquivo. Upload-Offset precisa ser
+// igual ao offset atual da sessão; ao receber o último byte o upload é
+// finalizado.
+func PatchUploadHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != chunkContentType {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type deve ser " + chunkContentType})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cabeçalho Upload-Offset inválido"})
+		return
+	}
+
+	if session.Status != models.UploadInProgress {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload não está em andamento", "status": session.Status})
+		return
+	}
+	if time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusGone, gin.H{"error": "Sessão de upload expirada"})
+		return
+	}
+	if offset != session.Offset {
+		setUploadHeaders(c, session)
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset não corresponde ao offset atual", "offset": session.Offset})
+		return
+	}
+
+	remaining := session.Size - session.Offset
+	limit := int64(maxChunkSize)
+	if remaining < limit {
+		limit = remaining
+	}
+	if c.Request.ContentLength > limit {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Parte excede o tamanho permitido", "max_size": limit})
+		return
+	}
+	body := &countingReader{r: http.MaxBytesReader(c.Writer, c.Request.Body, limit)}
+
+	ctx := c.Request.Context()
+	key := path.Join("uploads", fmt.Sprintf("%d", session.TenantID), session.UploadID,
+		fmt.Sprintf("%020d-%s", offset, uuid.New().String()[:8]))
+
+	if err := storage.Default.Put(ctx, key, body, c.Request.ContentLength, chunkContentType); err != nil {
+		storage.Default.Delete(ctx, key)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Parte excede o tamanho permitido", "max_size": limit})
+			return
+		}
+		// A parte é descartada; o cliente retoma a partir do mesmo offset
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gravar parte do upload"})
+		return
+	}
+	if body.n == 0 {
+		storage.Default.Delete(ctx, key)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parte vazia"})
+		return
+	}
+
+	chunk := models.UploadChunk{UploadID: session.UploadID, Offset: offset, Size: body.n, Key: key}
+	newOffset := offset + body.n
+
+	status := models.UploadInProgress
+	if newOffset == session.Size {
+		status = models.UploadCompleting
+	}
+
+	// O offset só avança se ninguém mais gravou esta mesma posição
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.UploadSession{}).
+			Where("id = ? AND \"offset\" = ? AND status = ?", session.ID, offset, models.UploadInProgress).
+			Updates(map[string]interface{}{"offset": newOffset, "status": status})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errOffsetConflict
+		}
+		return tx.Create(&chunk).Error
+	})
+	if err != nil {
+		storage.Default.Delete(ctx, key)
+		if errors.Is(err, errOffsetConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset não corresponde ao offset atual"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar sessão de upload"})
+		return
+	}
+	session.Offset = newOffset
+	session.Status = status
+
+	if session.Status == models.UploadInProgress {
+		setUploadHeaders(c, session)
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	// Montar e validar um arquivo de até 10GB não cabe no WriteTimeout do
+	// servidor
+	go completeUpload(session)
+
+	setUploadHeaders(c, session)
+	c.JSON(http.StatusAccepted, session)
+}
+
+// DeleteUploadHandler cancela o upload e apaga as partes já recebidas
+func DeleteUploadHandler(c *gin.Context) {
+	session, ok := loadUploadSession(c)
+	if !ok {
+		return
+	}
+	if session.Status == models.UploadCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload já foi concluído"})
+		return
+	}
+	if session.Status == models.UploadCompleting {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload em conclusão"})
+		return
+	}
+
+	if err := discardUploadSession(c.Request.Context(), session); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao cancelar upload"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+var errOffsetConflict = errors.New("offset do upload alterado concorrentemente")
+
+// completeUpload concatena as partes no backend de armazenamento, conferindo
+// o SHA-256 durante a cópia, e registra o Asset como no upload simples.
+// Qualquer falha encerra a sessão com o motivo em Error.
+func completeUpload(session models.UploadSession) {
+	ctx := queue.Ctx
+
+	var chunks []models.UploadChunk
+	if err := database.DB.Where("upload_id = ?", session.UploadID).Order("\"offset\" ASC").Find(&chunks).Error; err != nil {
+		log.Printf("Erro ao carregar partes do upload %s: %v", session.UploadID, err)
+		failUpload(ctx, session, "Erro ao carregar partes do upload")
+		return
+	}
+
+	var expectedOffset int64
+	for _, chunk := range chunks {
+		if chunk.Offset != expectedOffset {
+			failUpload(ctx, session, "Partes do upload inconsistentes")
+			return
+		}
+		expectedOffset += chunk.Size
+	}
+
+	withinQuota, err := checkTenantQuota(session.TenantID, session.Size)
+	if err != nil {
+		log.Printf("Erro ao verificar cota do upload %s: %v", session.UploadID, err)
+		failUpload(ctx, session, "Erro ao verificar cota de armazenamento")
+		return
+	}
+	if !withinQuota {
+		failUpload(ctx, session, "Cota de armazenamento do tenant excedida")
+		return
+	}
+
+	staged := stagingKey(session.TenantID)
+
+	size, checksum, err := storage.PutHashed(ctx, storage.Default, staged, &chunkReader{ctx: ctx, chunks: chunks}, session.ContentType)
+	if err != nil {
+		storage.Default.Delete(ctx, staged)
+		log.Printf("Erro ao montar arquivo do upload %s: %v", session.UploadID, err)
+		failUpload(ctx, session, "Erro ao montar arquivo")
+		return
+	}
+
+	if size != session.Size || checksum != session.Checksum {
+		storage.Default.Delete(ctx, staged)
+		failUpload(ctx, session, "Checksum do arquivo não confere")
+		return
+	}
+
+	asset, _, err := storeUpload(ctx, session.TenantID, session.UserID, staged, session.Filename, session.ContentType, size, checksum)
+	if err != nil {
+		log.Printf("Erro ao registrar upload %s: %v", session.UploadID, err)
+		failUpload(ctx, session, uploadErrorMessage(err))
+		return
+	}
+
+	database.DB.Model(&session).Where("status = ?", models.UploadCompleting).
+		Updates(models.UploadSession{Status: models.UploadCompleted, AssetID: &asset.ID})
+	deleteUploadChunks(ctx, session.UploadID)
+}
+
+// chunkReader lê as partes em sequência, abrindo cada uma só quando a
+// anterior termina
+type chunkReader struct {
+	ctx     context.Context
+	chunks  []models.UploadChunk
+	current io.ReadCloser
+}
+
+func (cr *chunkReader) Read(p []byte) (int, error) {
+	for {
+		if cr.current == nil {
+			if len(cr.chunks) == 0 {
+				return 0, io.EOF
+			}
+			r, err := storage.Default.Get(cr.ctx, cr.chunks[0].Key)
+			if err != nil {
+				return 0, err
+			}
+			cr.current = r
+			cr.chunks = cr.chunks[1:]
+		}
+
+		n, err := cr.current.Read(p)
+		if err == io.EOF {
+			cr.current.Close()
+			cr.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		if err != nil {
+			cr.current.Close()
+			cr.current = nil
+		}
+		return n, err
+	}
+}
+
+// failUpload marca a sessão como falha e descarta as partes
+func failUpload(ctx context.Context, session models.UploadSession, reason string) {
+	database.DB.Model(&session).Updates(models.UploadSession{Status: models.UploadFailed, Error: reason})
+	deleteUploadChunks(ctx, session.UploadID)
+}
+
+// discardUploadSession apaga as partes e a própria sessão
+func discardUploadSession(ctx context.Context, session models.UploadSession) error {
+	if err := deleteUploadChunks(ctx, session.UploadID); err != nil {
+		return err
+	}
+	return database.DB.Delete(&session).Error
+}
+
+// deleteUploadChunks remove as partes do backend e do banco
+func deleteUploadChunks(ctx context.Context, uploadID string) error {
+	var chunks []models.UploadChunk
+	if err := database.DB.Where("upload_id = ?", uploadID).Find(&chunks).Error; err != nil {
+		return err
+	}
+	for _, chunk := range chunks {
+		if err := storage.Default.Delete(ctx, chunk.Key); err != nil && !errors.Is(err, storage.ErrNotFound) {
+			return err
+		}
+	}
+	return database.DB.Where("upload_id = ?", uploadID).Delete(&models.UploadChunk{}).Error
+}
+
+// loadUploadSession carrega a sessão de :id pertencente ao usuário atual
+func loadUploadSession(c *gin.Context) (models.UploadSession, bool) {
+	user := currentUploader(c)
+
+	var session models.UploadSession
+	err := database.DB.Where("upload_id = ? AND user_id = ? AND tenant_id = ?", c.Param("id"), ownerID(user), user.TenantID).
+		First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload não encontrado"})
+		return session, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar upload"})
+		return session, false
+	}
+
+	return session, true
+}
+
+func setUploadHeaders(c *gin.Context, session models.UploadSession) {
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Size, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-store")
+}
+
+// countingReader conta os bytes lidos do corpo da requisição
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/validation"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+type CreateTenantRequest struct {
+	Name         string `json:"name" binding:"required"`
+	Slug         string `json:"slug" binding:"required"`
+	StorageQuota int64  `json:"storage_quota"`
+}
+
+type UpdateTenantRequest struct {
+	Name            *string                 `json:"name"`
+	StorageQuota    *int64                  `json:"storage_quota"`
+	ValidationRules *models.ValidationRules `json:"validation_rules"`
+}
+
+type AssignTenantRequest struct {
+	Role string `json:"role"`
+}
+
+// TenantUsage é um tenant com o espaço ocupado pelos seus assets
+type TenantUsage struct {
+	models.Tenant
+	StorageUsed int64 `json:"storage_used"`
+}
+
+func ListTenants(c *gin.Context) {
+	var tenants []models.Tenant
+	if err := database.DB.Find(&tenants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar tenants"})
+		return
+	}
+
+	result := make([]TenantUsage, 0, len(tenants))
+	for _, tenant := range tenants {
+		used, err := tenantStorageUsed(tenant.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao calcular uso de armazenamento"})
+			return
+		}
+		result = append(result, TenantUsage{Tenant: tenant, StorageUsed: used})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tenants": result})
+}
+
+func CreateTenant(c *gin.Context) {
+	var req CreateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome e slug são obrigatórios"})
+		return
+	}
+
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !slugPattern.MatchString(slug) || req.StorageQuota < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug ou cota inválidos"})
+		return
+	}
+
+	var count int64
+	if err := database.DB.Model(&models.Tenant{}).Where("slug = ?", slug).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar tenant"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Slug já utilizado"})
+		return
+	}
+
+	tenant := models.Tenant{
+		Name:         strings.TrimSpace(req.Name),
+		Slug:         slug,
+		StorageQuota: req.StorageQuota,
+	}
+	if err := database.DB.Create(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar tenant"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tenant)
+}
+
+func UpdateTenant(c *gin.Context) {
+	var req UpdateTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.StorageQuota != nil && *req.StorageQuota < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+	if req.ValidationRules != nil && !validRules(*req.ValidationRules) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Regras de validação inválidas"})
+		return
+	}
+
+	var tenant models.Tenant
+	if err := database.DB.First(&tenant, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant não encontrado"})
+		return
+	}
+
+	if req.Name != nil && strings.TrimSpace(*req.Name) != "" {
+		tenant.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.StorageQuota != nil {
+		tenant.StorageQuota = *req.StorageQuota
+	}
+	if req.ValidationRules != nil {
+		tenant.ValidationRules = *req.ValidationRules
+	}
+
+	if err := database.DB.Save(&tenant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar tenant"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tenant)
+}
+
+// validRules recusa limites negativos e tipos que não são aceitos no upload
+func validRules(rules models.ValidationRules) bool {
+	for contentType, limit := range rules.MaxSize {
+		if limit < 0 || !validation.Supported(contentType) {
+			return false
+		}
+	}
+	return rules.MaxPages >= 0 && rules.MaxDurationSeconds >= 0
+}
+
+// AssignUserToTenant move um usuário para o tenant. As sessões abertas são
+// revogadas porque os tokens carregam o tenant antigo.
+func AssignUserToTenant(c *gin.Context) {
+	var req AssignTenantRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Role != "" && !models.ValidRole(req.Role)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Papel inválido"})
+		return
+	}
+
+	var tenant models.Tenant
+	if err := database.DB.First(&tenant, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant não encontrado"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, c.Param("userId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return
+	}
+
+	user.TenantID = tenant.ID
+	if req.Role != "" {
+		user.Role = req.Role
+	}
+	if err := database.DB.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atribuir usuário ao tenant"})
+		return
+	}
+
+	// Os grupos são por tenant; a associação anterior deixa de fazer sentido
+	if err := database.DB.Unscoped().Where("user_id = ?", user.ID).Delete(&models.GroupMembership{}).Error; err != nil {
+		log.Printf("Erro ao remover grupos do usuário %d: %v", user.ID, err)
+	}
+	if err := auth.RevokeUserSessions(fmt.Sprintf("%d", user.ID)); err != nil {
+		log.Printf("Erro ao revogar sessões do usuário %d: %v", user.ID, err)
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// tenantStorageUsed soma o tamanho dos assets ativos do tenant, incluindo as
+// versões anteriores dos arquivos e os assets na lixeira, cujos arquivos só
+// são apagados no expurgo
+func tenantStorageUsed(tenantID uint) (int64, error) {
+	var used int64
+	err := database.DB.Unscoped().Model(&models.Asset{}).
+		Where("tenant_id = ? AND status <> ?", tenantID, models.StatusFailed).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&used).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var versions int64
+	err = database.DB.Model(&models.AssetVersion{}).
+		Joins("JOIN assets ON assets.id = asset_versions.asset_id").
+		Where("assets.tenant_id = ?", tenantID).
+		Select("COALESCE(SUM(asset_versions.size), 0)").
+		Scan(&versions).Error
+	return used + versions, err
+}
+
+// checkTenantQuota indica se o tenant comporta mais size bytes
+func checkTenantQuota(tenantID uint, size int64) (bool, error) {
+	var tenant models.Tenant
+	if err := database.DB.First(&tenant, tenantID).Error; err != nil {
+		return false, err
+	}
+	if tenant.StorageQuota == 0 {
+		return true, nil
+	}
+
+	used, err := tenantStorageUsed(tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	return used+size <= tenant.StorageQuota, nil
+}
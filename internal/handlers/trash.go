@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// trashRetention é o prazo para restaurar um asset apagado, definido por
+// ASSET_TRASH_RETENTION (ex.: "720h"); depois dele o asset é expurgado
+func trashRetention() time.Duration {
+	value := os.Getenv("ASSET_TRASH_RETENTION")
+	if value == "" {
+		return defaultTrashRetention
+	}
+	retention, err := time.ParseDuration(value)
+	if err != nil || retention <= 0 {
+		log.Printf("ASSET_TRASH_RETENTION inválido (%q), usando %s", value, defaultTrashRetention)
+		return defaultTrashRetention
+	}
+	return retention
+}
+
+// TrashedAsset é um asset na lixeira com a data a partir da qual será expurgado
+type TrashedAsset struct {
+	models.Asset
+	PurgeAfter time.Time `json:"purge_after"`
+}
+
+// DeletionSummary descreve tudo o que foi removido ao expurgar um asset
+type DeletionSummary struct {
+	AssetID uint   `json:"asset_id"`
+	Name    string `json:"name"`
+	// Arquivos originais (versão atual e anteriores) apagados do armazenamento
+	FilesDeleted []string `json:"files_deleted"`
+	// Arquivos mantidos porque outro asset do tenant tem o mesmo conteúdo
+	FilesRetained     []string `json:"files_retained"`
+	CachedCopies      []string `json:"cached_copies"`
+	QueuedJobs        int      `json:"queued_jobs"`
+	ProcessedAssets   int      `json:"processed_assets"`
+	Versions          int      `json:"versions"`
+	Grants            int64    `json:"grants"`
+	Tags              int64    `json:"tags"`
+	TechnicalMetadata int64    `json:"technical_metadata"`
+	// Falhas que não impediram o expurgo, como um arquivo que não pôde ser apagado
+	Errors []string `json:"errors,omitempty"`
+}
+
+// DeleteAsset move o asset para a lixeira. Ele deixa de aparecer nas
+// consultas, mas arquivos e registros relacionados são mantidos até o expurgo.
+func DeleteAsset(c *gin.Context) {
+	asset, user, ok := loadManagedAsset(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&asset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao apagar asset"})
+		return
+	}
+
+	now := time.Now()
+	log.Printf("Asset %d movido para a lixeira por %s", asset.ID, user.Email)
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Asset movido para a lixeira",
+		"asset_id":    asset.ID,
+		"deleted_at":  now,
+		"purge_after": now.Add(trashRetention()),
+	})
+}
+
+// ListTrash lista os assets apagados que o usuário pode restaurar ou expurgar
+func ListTrash(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	query := tenantScope(database.DB.Unscoped(), user, "assets").Where("assets.deleted_at IS NOT NULL")
+	if user.Role != models.RoleAdmin && user.Role != models.RoleSuperAdmin {
+		query = query.Where("assets.owner_id = ?", ownerID(user))
+	}
+
+	var assets []models.Asset
+	if err := query.Order("assets.deleted_at DESC").Find(&assets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar lixeira"})
+		return
+	}
+
+	retention := trashRetention()
+	trashed := make([]TrashedAsset, len(assets))
+	for i, asset := range assets {
+		trashed[i] = TrashedAsset{Asset: asset, PurgeAfter: asset.DeletedAt.Time.Add(retention)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"assets": trashed})
+}
+
+// RestoreAsset tira o asset da lixeira, desde que o prazo não tenha expirado
+func RestoreAsset(c *gin.Context) {
+	asset, ok := loadTrashed(c)
+	if !ok {
+		return
+	}
+
+	if time.Since(asset.DeletedAt.Time) > trashRetention() {
+		c.JSON(http.StatusGone, gin.H{"error": "Prazo para restauração expirado"})
+		return
+	}
+
+	if err := database.DB.Unscoped().Model(&asset).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao restaurar asset"})
+		return
+	}
+
+	asset.DeletedAt = gorm.DeletedAt{}
+	c.JSON(http.StatusOK, asset)
+}
+
+// PurgeTrashedAsset expurga imediatamente um asset da lixeira
+func PurgeTrashedAsset(c *gin.Context) {
+	asset, ok := loadTrashed(c)
+	if !ok {
+		return
+	}
+
+	summary, err := purgeAsset(c.Request.Context(), asset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao expurgar asset"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// loadTrashed busca um asset da lixeira que o usuário pode gerenciar
+func loadTrashed(c *gin.Context) (models.Asset, bool) {
+	var asset models.Asset
+
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return asset, false
+	}
+	user := userRaw.(auth.UserInfo)
+
+	err := database.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&asset, c.Param("id")).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Asset não encontrado na lixeira"})
+		return asset, false
+	}
+
+	if !canManageAsset(user, asset) {
+		auth.Forbidden(c)
+		return asset, false
+	}
+
+	return asset, true
+}
+
+// PurgeExpiredTrash expurga os assets que estão na lixeira há mais tempo que
+// ASSET_TRASH_RETENTION
+func PurgeExpiredTrash() {
+	cutoff := time.Now().Add(-trashRetention())
+
+	var assets []models.Asset
+	err := database.DB.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).Find(&assets).Error
+	if err != nil {
+		log.Printf("Erro ao buscar assets expirados na lixeira: %v", err)
+		return
+	}
+
+	for _, asset := range assets {
+		summary, err := purgeAsset(context.Background(), asset)
+		if err != nil {
+			log.Printf("Erro ao expurgar asset %d: %v", asset.ID, err)
+			continue
+		}
+		log.Printf("Asset %d expurgado da lixeira: %d arquivos apagados, %d cópias em cache, %d jobs",
+			asset.ID, len(summary.FilesDeleted), len(summary.CachedCopies), summary.QueuedJobs)
+	}
+}
+
+// purgeAsset apaga definitivamente o asset: jobs ainda na fila, registros
+// relacionados, cópias com marca d'água e os arquivos de todas as versões
+// que nenhum outro asset usa. Depois que os registros são removidos, falhas
+// ao apagar arquivos apenas entram no resumo.
+func purgeAsset(ctx context.Context, asset models.Asset) (DeletionSummary, error) {
+	summary := DeletionSummary{
+		AssetID:       asset.ID,
+		Name:          asset.Name,
+		FilesDeleted:  []string{},
+		FilesRetained: []string{},
+		CachedCopies:  []string{},
+	}
+
+	// Jobs removidos antes dos registros não chegam a gerar novas cópias
+	removed, err := queue.RemoveAssetJobs(asset.ID)
+	summary.QueuedJobs += removed
+	if err != nil {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("fila asset-queue: %v", err))
+	}
+	removed, err = redisQueue.RemoveJobsForAsset(strconv.FormatUint(uint64(asset.ID), 10))
+	summary.QueuedJobs += removed
+	if err != nil {
+		summary.Errors = append(summary.Errors, fmt.Sprintf("fila processing_queue: %v", err))
+	}
+
+	var versions []models.AssetVersion
+	var processed []models.ProcessedAsset
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("asset_id = ?", asset.ID).Find(&versions).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("asset_id = ?", asset.ID).Delete(&models.AssetVersion{}).Error; err != nil {
+			return err
+		}
+
+		// Registros já removidos pela limpeza do cache também são apagados
+		if err := tx.Unscoped().Where("asset_id = ?", asset.ID).Find(&processed).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("asset_id = ?", asset.ID).Delete(&models.ProcessedAsset{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("asset_id = ?", asset.ID).Delete(&models.AssetGrant{})
+		if result.Error != nil {
+			return result.Error
+		}
+		summary.Grants = result.RowsAffected
+
+		result = tx.Where("asset_id = ?", asset.ID).Delete(&models.AssetTag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		summary.Tags = result.RowsAffected
+
+		result = tx.Where("asset_id = ?", asset.ID).Delete(&models.TechnicalMetadata{})
+		if result.Error != nil {
+			return result.Error
+		}
+		summary.TechnicalMetadata = result.RowsAffected
+
+		return tx.Unscoped().Delete(&asset).Error
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Versions = len(versions)
+	summary.ProcessedAssets = len(processed)
+
+	for _, p := range processed {
+		if p.CachePath == "" {
+			continue
+		}
+		err := storage.Default.Delete(ctx, p.CachePath)
+		if err == nil {
+			summary.CachedCopies = append(summary.CachedCopies, p.CachePath)
+		} else if !errors.Is(err, storage.ErrNotFound) {
+			summary.Errors = append(summary.Errors, fmt.Sprintf("cópia em cache %s: %v", p.CachePath, err))
+		}
+	}
+
+	files := []models.Asset{asset}
+	for _, version := range versions {
+		files = append(files, models.Asset{TenantID: asset.TenantID, SHA256: version.SHA256, Path: version.Path})
+	}
+	for _, file := range files {
+		deleted, err := releaseBlob(ctx, file)
+		switch {
+		case err != nil && !errors.Is(err, storage.ErrNotFound):
+			summary.Errors = append(summary.Errors, fmt.Sprintf("arquivo %s: %v", file.Path, err))
+		case deleted:
+			summary.FilesDeleted = append(summary.FilesDeleted, file.Path)
+		default:
+			summary.FilesRetained = append(summary.FilesRetained, file.Path)
+		}
+	}
+
+	return summary, nil
+}
@@ -1,165 +1,407 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
 	"net/http"
-	"os"
-	"path/filepath"
+	"projeto_drm/poc/internal/auth"
 	"projeto_drm/poc/internal/database"
 	"projeto_drm/poc/internal/models"
 	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
+	"projeto_drm/poc/internal/validation"
+	"strconv"
 	"strings"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
+const maxUploadSize = 1 << 30 // 1GB
+
 func UploadHandler(c *gin.Context) {
-	const maxUploadSize = 1 << 30 // 1GB
+	part, file, contentType, ok := readUploadPart(c)
+	if !ok {
+		return
+	}
+	defer part.Close()
+	filename := part.FileName()
+
+	user := currentUploader(c)
+
+	// O tamanho exato só é conhecido ao fim da gravação, quando a cota é
+	// conferida de novo; o Content-Length, se enviado, é um limite superior
+	if c.Request.ContentLength >= 0 && !checkUploadQuota(c, user.TenantID, c.Request.ContentLength) {
+		return
+	}
+
+	// Com a política "reject" o nome repetido é recusado antes de receber o arquivo
+	if _, err := resolveAssetName(user.TenantID, filename); err != nil {
+		respondNameError(c, err)
+		return
+	}
+
+	uploadFile(c, file, filename, contentType, user)
+}
+
+// checkUploadQuota responde 413 quando o tenant não comporta mais size bytes.
+// Em caso de erro a resposta já foi enviada e o retorno é falso.
+func checkUploadQuota(c *gin.Context, tenantID uint, size int64) bool {
+	withinQuota, err := checkTenantQuota(tenantID, size)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar cota de armazenamento"})
+		return false
+	}
+	if !withinQuota {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Cota de armazenamento do tenant excedida"})
+		return false
+	}
+	return true
+}
+
+// readUploadPart abre o campo "file" do corpo multipart, limitado a 1GB, e
+// identifica o tipo pelos primeiros bytes. file lê o conteúdo completo,
+// inclusive os bytes já inspecionados. Em caso de erro a resposta já foi
+// enviada e ok é falso.
+func readUploadPart(c *gin.Context) (part *multipart.Part, file io.Reader, contentType string, ok bool) {
 	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxUploadSize)
 
-	file, header, err := c.Request.FormFile("file")
+	// O arquivo é lido direto do corpo multipart, sem passar pelo disco
+	part, err := fileFormPart(c.Request, "file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Arquivo não foi enviado ou excede o limite de 1GB"})
-		return
+		return nil, nil, "", false
 	}
-	defer file.Close()
 
-	allowedTypes(header.Header.Get("Content-Type"), c)
-	if strings.Contains(header.Filename, "..") {
+	if strings.Contains(part.FileName(), "..") {
+		part.Close()
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Nome de arquivo inválido"})
-		return
+		return nil, nil, "", false
 	}
 
-	var existingAsset models.Asset
-	err = database.DB.Where("name = ?", header.Filename).First(&existingAsset).Error
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar existência do arquivo"})
-		return
+	// O tipo vem dos primeiros bytes do arquivo, não do Content-Type enviado
+	// pelo cliente; divergências são recusadas antes de receber o restante
+	buffered := bufio.NewReaderSize(part, validation.SniffLength)
+	head, err := buffered.Peek(validation.SniffLength)
+	if err != nil && !errors.Is(err, io.EOF) {
+		part.Close()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Erro ao ler arquivo enviado"})
+		return nil, nil, "", false
 	}
-	if err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Arquivo já existe"})
-		return
+	contentType = validation.Sniff(head)
+	if err := validation.CheckType(part.FileName(), part.Header.Get("Content-Type"), contentType); err != nil {
+		part.Close()
+		respondValidationError(c, http.StatusUnsupportedMediaType, err)
+		return nil, nil, "", false
 	}
 
-	storageType := os.Getenv("STORAGE_TYPE")
-	log.Println("Tipo de armazenamento:", storageType)
-	if storageType == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tipo de armazenamento não definido"})
-		return
-	}
+	return part, buffered, contentType, true
+}
 
-	switch storageType {
-	case "local":
-		uploadLocalFile(c, file, header)
-		break
-	case "s3":
-		uploadS3File(c, file, header)
-		break
+// fileFormPart avança o corpo multipart até o campo de arquivo name
+func fileFormPart(r *http.Request, name string) (*multipart.Part, error) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
 
-	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tipo de armazenamento não suportado"})
-		return
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == name && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
 	}
 }
 
-func allowedTypes(contentType string, c *gin.Context) {
-	allowedTypes := map[string]bool{
-		"application/pdf": true,
-		"video/mp4":       true,
-		"video/quicktime": true,
+// uploadFile grava o arquivo em uma única passagem no backend de
+// armazenamento, calculando tamanho e SHA-256 durante a cópia, e o registra
+// como blob endereçado pelo conteúdo
+func uploadFile(c *gin.Context, file io.Reader, filename, contentType string, user auth.UserInfo) {
+	staged, size, checksum, ok := stageUpload(c, file, contentType, user.TenantID)
+	if !ok {
+		return
 	}
 
-	if !allowedTypes[contentType] {
-		fmt.Println("Tipo de arquivo não suportado:", contentType)
-		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Tipo de arquivo não suportado"})
-		c.Abort()
+	asset, deduplicated, ok := registerUpload(c, user, staged, filename, contentType, size, checksum)
+	if !ok {
 		return
 	}
+
+	c.JSON(http.StatusOK, uploadResponse(asset, deduplicated))
 }
 
-func uploadLocalFile(c *gin.Context, file io.Reader, header *multipart.FileHeader) {
-	tempDir := "temp"
-	if err := os.MkdirAll(tempDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar diretório temporário"})
-		return
+// stageUpload grava o arquivo na área de staging do tenant. Em caso de erro a
+// resposta já foi enviada e ok é falso.
+func stageUpload(c *gin.Context, file io.Reader, contentType string, tenantID uint) (staged string, size int64, checksum string, ok bool) {
+	ctx := c.Request.Context()
+
+	// Cada tenant tem o seu próprio prefixo de armazenamento
+	staged = stagingKey(tenantID)
+
+	size, checksum, err := storage.PutHashed(ctx, storage.Default, staged, file, contentType)
+	if err != nil {
+		storage.Default.Delete(ctx, staged)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Arquivo excede o limite de 1GB"})
+			return "", 0, "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo"})
+		return "", 0, "", false
 	}
+	return staged, size, checksum, true
+}
 
-	safeName := filepath.Base(header.Filename)
-	uniqueName := fmt.Sprintf("%d_%s", time.Now().UnixNano(), safeName)
-	dstPath := filepath.Join(tempDir, uniqueName)
+// registerUpload valida o conteúdo já gravado em staged, aplica a política de
+// nomes, move o conteúdo para o seu blob e cria o Asset. Em caso de erro a
+// resposta já foi enviada e ok é falso.
+func registerUpload(c *gin.Context, user auth.UserInfo, staged, filename, contentType string, size int64, checksum string) (asset models.Asset, deduplicated bool, ok bool) {
+	asset, deduplicated, err := storeUpload(c.Request.Context(), user.TenantID, ownerID(user), staged, filename, contentType, size, checksum)
+	if err != nil {
+		respondUploadError(c, err)
+		return asset, false, false
+	}
+	return asset, deduplicated, true
+}
 
-	if _, err := os.Stat(dstPath); err == nil {
-		c.JSON(http.StatusConflict, gin.H{"error": "Arquivo já existe no sistema"})
-		return
+var (
+	errUploadValidation = errors.New("erro ao validar arquivo")
+	errUploadName       = errors.New("erro ao verificar existência do arquivo")
+	errBlobCommit       = errors.New("erro ao salvar arquivo")
+	errQuotaCheck       = errors.New("erro ao verificar cota de armazenamento")
+	errQuotaExceeded    = errors.New("cota de armazenamento do tenant excedida")
+)
+
+// storeUpload faz o trabalho do registerUpload sem depender da requisição,
+// para que o upload resumível possa concluir em segundo plano. Em caso de
+// erro o conteúdo em staged é descartado.
+func storeUpload(ctx context.Context, tenantID, owner uint, staged, filename, contentType string, size int64, checksum string) (asset models.Asset, deduplicated bool, err error) {
+	// A cota é conferida com o tamanho real gravado
+	withinQuota, err := checkTenantQuota(tenantID, size)
+	if err != nil {
+		storage.Default.Delete(ctx, staged)
+		return asset, false, fmt.Errorf("%w: %w", errQuotaCheck, err)
+	}
+	if !withinQuota {
+		storage.Default.Delete(ctx, staged)
+		return asset, false, errQuotaExceeded
 	}
 
-	// Create a temporary file to store the uploaded content
-	tempFile, err := os.CreateTemp("", "upload-*")
+	contentType, err = validateUpload(ctx, tenantID, staged, filename, contentType, size)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao criar arquivo temporário"})
-		return
+		storage.Default.Delete(ctx, staged)
+		return asset, false, fmt.Errorf("%w: %w", errUploadValidation, err)
 	}
-	tempFilePath := tempFile.Name()
-	defer tempFile.Close()
 
-	// Copy the uploaded file to the temporary file
-	_, err = io.Copy(tempFile, file)
+	name, err := resolveAssetName(tenantID, filename)
 	if err != nil {
-		os.Remove(tempFilePath) // Clean up the temporary file
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo temporário"})
-		return
+		storage.Default.Delete(ctx, staged)
+		return asset, false, fmt.Errorf("%w: %w", errUploadName, err)
+	}
+
+	blob, deduplicated, err := commitBlob(ctx, tenantID, staged, checksum, size, contentType)
+	if err != nil {
+		storage.Default.Delete(ctx, staged)
+		return asset, false, fmt.Errorf("%w: %w", errBlobCommit, err)
 	}
 
-	asset := models.Asset{
-		Name:      header.Filename,
-		Type:      header.Header.Get("Content-Type"),
-		Size:      header.Size,
-		Path:      dstPath,
+	asset = models.Asset{
+		Name:      name,
+		Type:      contentType,
+		Size:      size,
+		SHA256:    checksum,
+		Path:      blob.Path,
 		Status:    models.StatusPending,
+		Version:   1,
 		Encrypted: false,
+		OwnerID:   owner,
+		TenantID:  tenantID,
 	}
 
-	if err := database.DB.Create(&asset).Error; err != nil {
-		os.Remove(tempFilePath) // Clean up the temporary file
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar metadados no banco"})
+	if err := createAndEnqueueAsset(&asset); err != nil {
+		if asset.ID == 0 {
+			releaseBlob(ctx, asset)
+		}
+		return asset, false, err
+	}
+
+	return asset, deduplicated, nil
+}
+
+// respondUploadError traduz os erros de storeUpload
+func respondUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Cota de armazenamento do tenant excedida"})
+	case errors.Is(err, errQuotaCheck):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar cota de armazenamento"})
+	case errors.Is(err, errUploadValidation):
+		respondValidationError(c, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, errUploadName):
+		respondNameError(c, err)
+	case errors.Is(err, errBlobCommit):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar arquivo"})
+	default:
+		respondAssetError(c, err)
+	}
+}
+
+// uploadErrorMessage descreve os erros de storeUpload com as mesmas mensagens
+// das respostas de respondUploadError
+func uploadErrorMessage(err error) string {
+	var rejected *validation.RejectedError
+	switch {
+	case errors.Is(err, errQuotaExceeded):
+		return "Cota de armazenamento do tenant excedida"
+	case errors.Is(err, errQuotaCheck):
+		return "Erro ao verificar cota de armazenamento"
+	case errors.As(err, &rejected):
+		return "Arquivo recusado na validação: " + rejected.Error()
+	case errors.Is(err, errUploadValidation):
+		return "Erro ao validar arquivo"
+	case errors.Is(err, errNameTaken):
+		return "Arquivo já existe"
+	case errors.Is(err, errUploadName):
+		return "Erro ao verificar existência do arquivo"
+	case errors.Is(err, errBlobCommit):
+		return "Erro ao salvar arquivo"
+	case errors.Is(err, errAssetEnqueue):
+		return "Erro ao enfileirar processamento"
+	default:
+		return "Erro ao salvar metadados no banco"
+	}
+}
+
+func uploadResponse(asset models.Asset, deduplicated bool) gin.H {
+	return gin.H{
+		"message":      "Arquivo enviado com sucesso",
+		"filename":     asset.Name,
+		"path":         asset.Path,
+		"size":         asset.Size,
+		"sha256":       asset.SHA256,
+		"deduplicated": deduplicated,
+		"type":         asset.Type,
+		"asset_id":     asset.ID,
+		"download_url": fmt.Sprintf("/assets/%d/download", asset.ID),
+		"status":       asset.Status,
+	}
+}
+
+// validateUpload roda o pipeline de validação do tenant sobre o arquivo em
+// staged e retorna o tipo detectado pelo conteúdo
+func validateUpload(ctx context.Context, tenantID uint, staged, filename, declaredType string, size int64) (string, error) {
+	var tenant models.Tenant
+	if err := database.DB.First(&tenant, tenantID).Error; err != nil {
+		return "", err
+	}
+
+	// pdfcpu e ffprobe precisam de um arquivo local
+	localPath, cleanup, err := storage.FetchToFile(ctx, storage.Default, staged)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+
+	file := &validation.File{
+		Path:         localPath,
+		Name:         filename,
+		DeclaredType: declaredType,
+		Size:         size,
+	}
+	if err := validation.ForTenant(tenant.ValidationRules).Run(ctx, file); err != nil {
+		return "", err
+	}
+	return file.DetectedType, nil
+}
+
+// respondValidationError responde status quando o arquivo foi recusado por
+// uma checagem e 500 quando a própria validação falhou
+func respondValidationError(c *gin.Context, status int, err error) {
+	var rejected *validation.RejectedError
+	if errors.As(err, &rejected) {
+		c.JSON(status, gin.H{
+			"error":  "Arquivo recusado na validação",
+			"check":  rejected.Check,
+			"reason": rejected.Reason,
+		})
 		return
 	}
+	log.Printf("Erro ao validar arquivo: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao validar arquivo"})
+}
 
+// respondNameError traduz os erros de resolveAssetName
+func respondNameError(c *gin.Context, err error) {
+	if errors.Is(err, errNameTaken) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Arquivo já existe"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao verificar existência do arquivo"})
+}
+
+var (
+	errAssetCreate  = errors.New("erro ao salvar metadados no banco")
+	errAssetEnqueue = errors.New("erro ao enfileirar processamento")
+)
+
+// createAndEnqueueAsset grava o Asset, cujo arquivo já está em asset.Path, e
+// enfileira o pós-processamento assíncrono
+func createAndEnqueueAsset(asset *models.Asset) error {
+	if err := database.DB.Create(asset).Error; err != nil {
+		return errAssetCreate
+	}
+	return enqueueAsset(asset)
+}
+
+// enqueueAsset enfileira o pós-processamento da versão atual do asset,
+// marcando-o como falho se a fila não estiver disponível
+func enqueueAsset(asset *models.Asset) error {
 	job := queue.AssetJob{
-		ID:           asset.ID,
-		Path:         dstPath,
-		Type:         asset.Type,
-		TempFilePath: tempFilePath,
+		ID:       asset.ID,
+		Path:     asset.Path,
+		Type:     asset.Type,
+		TenantID: asset.TenantID,
+		Version:  asset.Version,
 	}
 
 	if err := queue.EnqueueAssetJob(job); err != nil {
-		os.Remove(tempFilePath) // Clean up the temporary file
-		database.DB.Model(&asset).Updates(models.Asset{Status: models.StatusFailed})
+		database.DB.Model(asset).Where("version = ?", asset.Version).Updates(models.Asset{Status: models.StatusFailed})
+		return errAssetEnqueue
+	}
+	return nil
+}
+
+// respondAssetError traduz os erros de createAndEnqueueAsset
+func respondAssetError(c *gin.Context, err error) {
+	if errors.Is(err, errAssetEnqueue) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao enfileirar processamento"})
 		return
 	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar metadados no banco"})
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Arquivo enviado com sucesso",
-		"filename":     header.Filename,
-		"path":         dstPath,
-		"size":         header.Size,
-		"type":         header.Header.Get("Content-Type"),
-		"asset_id":     asset.ID,
-		"download_url": fmt.Sprintf("/assets/%d/download", asset.ID),
-		"status":       asset.Status,
-	})
+// currentUploader retorna o usuário autenticado que está realizando o upload
+func currentUploader(c *gin.Context) auth.UserInfo {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		return auth.UserInfo{TenantID: database.DefaultTenantID}
+	}
+	return userRaw.(auth.UserInfo)
 }
 
-func uploadS3File(c *gin.Context, file io.Reader, header *multipart.FileHeader) {
-	// Implementar upload para S3
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Upload para S3 não implementado",
-	})
+// ownerID retorna o ID numérico do usuário que realizou o upload
+func ownerID(user auth.UserInfo) uint {
+	id, err := strconv.ParseUint(user.ID, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type UpdateRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// loadTenantUser busca o usuário da rota, restrito ao tenant de quem chama
+func loadTenantUser(c *gin.Context) (models.User, auth.UserInfo, bool) {
+	var target models.User
+
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return target, auth.UserInfo{}, false
+	}
+	user := userRaw.(auth.UserInfo)
+
+	if err := tenantScope(database.DB, user, "users").First(&target, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		return target, user, false
+	}
+
+	return target, user, true
+}
+
+func ListUsers(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var users []models.User
+
+	if err := tenantScope(database.DB, user, "users").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar usuários"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users})
+}
+
+func UpdateUserRole(c *gin.Context) {
+	var req UpdateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Papel inválido"})
+		return
+	}
+
+	target, user, ok := loadTenantUser(c)
+	if !ok {
+		return
+	}
+
+	// Apenas o superadmin concede ou retira o papel de superadmin
+	if (req.Role == models.RoleSuperAdmin || target.Role == models.RoleSuperAdmin) && user.Role != models.RoleSuperAdmin {
+		auth.Forbidden(c)
+		return
+	}
+
+	target.Role = req.Role
+	if err := database.DB.Save(&target).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar papel do usuário"})
+		return
+	}
+
+	c.JSON(http.StatusOK, target)
+}
+
+type GroupRequest struct {
+	Group string `json:"group" binding:"required"`
+}
+
+func AddUserToGroup(c *gin.Context) {
+	var req GroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Group) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Grupo é obrigatório"})
+		return
+	}
+
+	target, _, ok := loadTenantUser(c)
+	if !ok {
+		return
+	}
+
+	membership := models.GroupMembership{UserID: target.ID, GroupName: strings.TrimSpace(req.Group)}
+	if err := database.DB.Where(membership).FirstOrCreate(&membership).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao adicionar usuário ao grupo"})
+		return
+	}
+
+	c.JSON(http.StatusOK, membership)
+}
+
+func RemoveUserFromGroup(c *gin.Context) {
+	target, _, ok := loadTenantUser(c)
+	if !ok {
+		return
+	}
+
+	result := database.DB.Unscoped().
+		Where("user_id = ? AND group_name = ?", target.ID, c.Param("group")).
+		Delete(&models.GroupMembership{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover usuário do grupo"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Usuário não pertence ao grupo"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeUserSessions encerra imediatamente todas as sessões de um usuário
+func RevokeUserSessions(c *gin.Context) {
+	target, _, ok := loadTenantUser(c)
+	if !ok {
+		return
+	}
+
+	if err := auth.RevokeUserSessions(fmt.Sprintf("%d", target.ID)); err != nil {
+		log.Printf("Erro ao revogar sessões do usuário %d: %v", target.ID, err)
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Não foi possível revogar as sessões"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"projeto_drm/poc/internal/auth"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/webhook"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" binding:"required"`
+	Events      []string `json:"events" binding:"required,min=1"`
+	Description string   `json:"description"`
+	// TenantWide faz o webhook receber os eventos de todo o tenant (somente admin)
+	TenantWide bool `json:"tenant_wide"`
+}
+
+// UpdateWebhookRequest altera apenas os campos enviados
+type UpdateWebhookRequest struct {
+	URL         *string   `json:"url"`
+	Events      *[]string `json:"events"`
+	Description *string   `json:"description"`
+	TenantWide  *bool     `json:"tenant_wide"`
+	Active      *bool     `json:"active"`
+}
+
+// CreateWebhook registra um endpoint para receber eventos. O segredo de
+// assinatura só é exibido nesta resposta.
+func CreateWebhook(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL e eventos são obrigatórios"})
+		return
+	}
+	if !validWebhookRequest(c, user, req.URL, req.Events, req.TenantWide) {
+		return
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao gerar segredo do webhook"})
+		return
+	}
+
+	hook := models.Webhook{
+		TenantID:    user.TenantID,
+		UserID:      ownerID(user),
+		TenantWide:  req.TenantWide,
+		URL:         strings.TrimSpace(req.URL),
+		Description: strings.TrimSpace(req.Description),
+		Events:      req.Events,
+		Secret:      secret,
+		Active:      true,
+	}
+	if err := database.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao salvar webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"secret":  secret,
+		"webhook": hook,
+	})
+}
+
+// ListWebhooks lista os webhooks do usuário; administradores veem todos os
+// webhooks do tenant
+func ListWebhooks(c *gin.Context) {
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return
+	}
+	user := userRaw.(auth.UserInfo)
+
+	query := tenantScope(database.DB, user, "webhooks")
+	if user.Role != models.RoleAdmin && user.Role != models.RoleSuperAdmin {
+		query = query.Where("webhooks.user_id = ?", ownerID(user))
+	}
+
+	var hooks []models.Webhook
+	if err := query.Order("id").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"webhooks": hooks})
+}
+
+// UpdateWebhook altera a URL, os eventos, a descrição ou desativa o webhook
+func UpdateWebhook(c *gin.Context) {
+	hook, user, ok := loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Requisição inválida"})
+		return
+	}
+
+	if req.URL != nil {
+		hook.URL = strings.TrimSpace(*req.URL)
+	}
+	if req.Events != nil {
+		hook.Events = *req.Events
+	}
+	if req.Description != nil {
+		hook.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.TenantWide != nil {
+		hook.TenantWide = *req.TenantWide
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	// Só é preciso ser admin para tornar o webhook de tenant, não para editá-lo
+	tenantWide := req.TenantWide != nil && *req.TenantWide
+	if !validWebhookRequest(c, user, hook.URL, hook.Events, tenantWide) {
+		return
+	}
+
+	err := database.DB.Model(&hook).
+		Select("url", "events", "description", "tenant_wide", "active").
+		Updates(&hook).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao atualizar webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook remove o webhook; entregas pendentes não são mais enviadas
+func DeleteWebhook(c *gin.Context) {
+	hook, _, ok := loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao remover webhook"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListWebhookDeliveries mostra o log de entregas do webhook, das mais
+// recentes para as mais antigas, opcionalmente filtrado por ?status=. O corpo
+// das respostas só é exibido aos administradores.
+func ListWebhookDeliveries(c *gin.Context) {
+	hook, user, ok := loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit deve estar entre 1 e 200"})
+			return
+		}
+		limit = parsed
+	}
+
+	query := database.DB.Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar entregas"})
+		return
+	}
+
+	if user.Role != models.RoleAdmin && user.Role != models.RoleSuperAdmin {
+		for i := range deliveries {
+			deliveries[i].ResponseBody = ""
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"deliveries": deliveries})
+}
+
+// loadWebhook busca o webhook da rota, que pode ser gerenciado pelo dono e
+// pelos administradores do tenant
+func loadWebhook(c *gin.Context) (models.Webhook, auth.UserInfo, bool) {
+	var hook models.Webhook
+
+	userRaw, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Usuário não autenticado"})
+		return hook, auth.UserInfo{}, false
+	}
+	user := userRaw.(auth.UserInfo)
+
+	err := database.DB.First(&hook, c.Param("id")).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook não encontrado"})
+		return hook, user, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao buscar webhook"})
+		return hook, user, false
+	}
+
+	// Webhooks de outros usuários são tratados como inexistentes
+	isAdmin := user.Role == models.RoleAdmin || user.Role == models.RoleSuperAdmin
+	if !sameTenant(user, hook.TenantID) || (hook.UserID != ownerID(user) && !isAdmin) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook não encontrado"})
+		return hook, user, false
+	}
+
+	return hook, user, true
+}
+
+// validWebhookRequest confere a URL, os eventos e a permissão para webhooks de tenant
+func validWebhookRequest(c *gin.Context, user auth.UserInfo, rawURL string, events []string, tenantWide bool) bool {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL do webhook inválida"})
+		return false
+	}
+	if !webhook.AllowedHost(parsed.Hostname()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "URL do webhook aponta para uma rede interna"})
+		return false
+	}
+
+	if len(events) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Informe ao menos um evento", "events": models.WebhookEvents})
+		return false
+	}
+	for _, event := range events {
+		valid := false
+		for _, known := range models.WebhookEvents {
+			if event == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Evento inválido: " + event, "events": models.WebhookEvents})
+			return false
+		}
+	}
+
+	if tenantWide && user.Role != models.RoleAdmin && user.Role != models.RoleSuperAdmin {
+		auth.Forbidden(c)
+		return false
+	}
+
+	return true
+}
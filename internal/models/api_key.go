@@ -0,0 +1,20 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// APIKey é uma credencial de longa duração para integrações sem login
+// interativo. Apenas o hash SHA-256 da chave é armazenado; o prefixo permite
+// identificá-la sem expor o segredo.
+type APIKey struct {
+	gorm.Model
+	UserID     uint       `json:"user_id" gorm:"index;not null"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix" gorm:"uniqueIndex;not null"`
+	KeyHash    string     `json:"-" gorm:"not null"`
+	Scopes     string     `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+}
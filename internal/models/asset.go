@@ -11,18 +11,47 @@ type Asset struct {
 	Path      string `json:"path"`
 	Type      string `json:"type"`
 	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256" gorm:"index"`
 	Status    string `json:"status" gorm:"default:pending"`
 	Encrypted bool   `json:"encrypted"`
+	OwnerID   uint   `json:"owner_id" gorm:"index"`
+	TenantID  uint   `json:"tenant_id" gorm:"index"`
+
+	// Versão atual do arquivo; as anteriores ficam em AssetVersion. Quem
+	// enviou e quando só são preenchidos a partir da segunda versão.
+	Version           int        `json:"version" gorm:"default:1"`
+	VersionUploadedBy uint       `json:"version_uploaded_by,omitempty"`
+	VersionCreatedAt  *time.Time `json:"version_created_at,omitempty"`
+
+	// Metadados editáveis pelos bibliotecários
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Author      string            `json:"author"`
+	Metadata    map[string]string `json:"metadata" gorm:"serializer:json;type:text"`
+	// Tags são gravadas em AssetTag; o campo é preenchido ao consultar
+	Tags []string `json:"tags" gorm:"-"`
+	// Metadados técnicos extraídos do arquivo, preenchidos em GET /assets/:id
+	Technical *TechnicalMetadata `json:"technical_metadata,omitempty" gorm:"-"`
+
+	// Resultado do antivírus: a assinatura encontrada, quando houver
+	ScanResult string     `json:"scan_result,omitempty"`
+	ScannedAt  *time.Time `json:"scanned_at,omitempty"`
+	ReleasedBy *uint      `json:"released_by,omitempty"`
 }
 
 type ProcessedAsset struct {
 	gorm.Model
-	AssetID     uint       `json:"asset_id" gorm:"index"`
-	UserID      uint       `json:"user_id" gorm:"index"`
-	Status      string     `json:"status" gorm:"default:queued"` // "queued", "processing", "completed", "failed"
-	CachePath   string     `json:"cache_path"`
-	ProcessedAt *time.Time `json:"processed_at"`
-	ErrorMsg    string     `json:"error_msg,omitempty"`
+	AssetID  uint `json:"asset_id" gorm:"index"`
+	UserID   uint `json:"user_id" gorm:"index"`
+	TenantID uint `json:"tenant_id" gorm:"index"`
+	// AssetVersion é a versão do arquivo usada para gerar a cópia
+	AssetVersion int        `json:"asset_version" gorm:"default:1;index"`
+	Status       string     `json:"status" gorm:"default:queued"` // "queued", "processing", "completed", "failed"
+	CachePath    string     `json:"cache_path"`
+	ProcessedAt  *time.Time `json:"processed_at"`
+	ErrorMsg     string     `json:"error_msg,omitempty"`
+	// JobID é o último job de processamento enfileirado, consultado em GET /jobs/:id
+	JobID string `json:"job_id,omitempty" gorm:"index"`
 }
 
 func (ProcessedAsset) TableName() string {
@@ -0,0 +1,9 @@
+package models
+
+// AssetTag associa uma tag livre a um asset. As tags são normalizadas em
+// minúsculas para que o filtro não dependa de maiúsculas.
+type AssetTag struct {
+	ID      uint   `gorm:"primaryKey"`
+	AssetID uint   `gorm:"uniqueIndex:idx_asset_tag;not null"`
+	Tag     string `gorm:"uniqueIndex:idx_asset_tag;index;not null"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AssetVersion guarda uma versão anterior do arquivo de um asset. A versão
+// atual continua nos campos do próprio Asset; ao enviar uma nova, a atual é
+// copiada para cá e mantém a sua referência ao blob.
+type AssetVersion struct {
+	ID         uint       `json:"-" gorm:"primaryKey"`
+	AssetID    uint       `json:"asset_id" gorm:"uniqueIndex:idx_asset_version;not null"`
+	Version    int        `json:"version" gorm:"uniqueIndex:idx_asset_version;not null"`
+	Path       string     `json:"path"`
+	Type       string     `json:"type"`
+	Size       int64      `json:"size"`
+	SHA256     string     `json:"sha256" gorm:"index"`
+	Status     string     `json:"status"`
+	UploadedBy uint       `json:"uploaded_by"`
+	CreatedAt  time.Time  `json:"created_at"` // quando a versão foi enviada
+	ReplacedAt *time.Time `json:"replaced_at,omitempty"`
+}
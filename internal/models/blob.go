@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Blob é um conteúdo armazenado uma única vez por tenant, endereçado pelo
+// SHA-256. Vários Assets podem apontar para o mesmo Blob; RefCount conta
+// quantos, e o objeto é removido do armazenamento quando chega a zero.
+type Blob struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TenantID    uint      `json:"tenant_id" gorm:"uniqueIndex:idx_blob_tenant_hash;not null"`
+	SHA256      string    `json:"sha256" gorm:"uniqueIndex:idx_blob_tenant_hash;not null"`
+	Path        string    `json:"path" gorm:"not null"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"content_type"`
+	RefCount    int       `json:"ref_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// AssetGrant concede acesso a um asset para um usuário ou para um grupo
+type AssetGrant struct {
+	gorm.Model
+	AssetID   uint       `json:"asset_id" gorm:"index;not null"`
+	UserID    *uint      `json:"user_id,omitempty" gorm:"index"`
+	GroupName string     `json:"group,omitempty" gorm:"index"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	GrantedBy uint       `json:"granted_by"`
+}
+
+// GroupMembership associa um usuário a um grupo nomeado
+type GroupMembership struct {
+	gorm.Model
+	UserID    uint   `json:"user_id" gorm:"uniqueIndex:idx_group_member;not null"`
+	GroupName string `json:"group" gorm:"uniqueIndex:idx_group_member;not null"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// RecoveryCode é um código de uso único para acessar a conta sem o app
+// autenticador. Apenas o hash é armazenado.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `json:"-" gorm:"index;not null"`
+	CodeHash string     `json:"-" gorm:"not null"`
+	UsedAt   *time.Time `json:"used_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+const (
+	// RoleSuperAdmin administra a plataforma e todos os tenants
+	RoleSuperAdmin = "superadmin"
+	// RoleAdmin administra apenas o próprio tenant
+	RoleAdmin    = "admin"
+	RoleUploader = "uploader"
+	RoleViewer   = "viewer"
+)
+
+// ValidRole indica se o papel informado é conhecido pelo sistema
+func ValidRole(role string) bool {
+	switch role {
+	case RoleSuperAdmin, RoleAdmin, RoleUploader, RoleViewer:
+		return true
+	}
+	return false
+}
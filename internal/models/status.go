@@ -5,4 +5,7 @@ const (
 	StatusProcessing = "processing"
 	StatusCompleted  = "completed"
 	StatusFailed     = "failed"
+	// StatusQuarantined marca assets em que o antivírus encontrou uma ameaça;
+	// ficam indisponíveis até a revisão de um administrador
+	StatusQuarantined = "quarantined"
 )
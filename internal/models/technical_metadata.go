@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+// TechnicalMetadata guarda os dados extraídos do próprio arquivo após o
+// processamento. Apenas os campos do tipo do asset (PDF ou vídeo) são
+// preenchidos.
+type TechnicalMetadata struct {
+	ID      uint   `json:"-" gorm:"primaryKey"`
+	AssetID uint   `json:"asset_id" gorm:"uniqueIndex;not null"`
+	Kind    string `json:"kind"` // "pdf" ou "video"
+
+	// PDF; as dimensões são as da primeira página, em pontos
+	PDFVersion string  `json:"pdf_version,omitempty"`
+	PageCount  int     `json:"page_count,omitempty"`
+	PageWidth  float64 `json:"page_width,omitempty"`
+	PageHeight float64 `json:"page_height,omitempty"`
+	Encrypted  bool    `json:"encrypted,omitempty"`
+	Producer   string  `json:"producer,omitempty"`
+
+	// Vídeo
+	DurationSeconds float64      `json:"duration_seconds,omitempty"`
+	Width           int          `json:"width,omitempty"`
+	Height          int          `json:"height,omitempty"`
+	VideoCodec      string       `json:"video_codec,omitempty"`
+	Bitrate         int64        `json:"bitrate,omitempty"`
+	AudioTracks     []AudioTrack `json:"audio_tracks,omitempty" gorm:"serializer:json;type:text"`
+
+	ExtractedAt time.Time `json:"extracted_at"`
+}
+
+func (TechnicalMetadata) TableName() string {
+	return "technical_metadata"
+}
+
+// AudioTrack descreve uma trilha de áudio de um vídeo
+type AudioTrack struct {
+	Codec    string `json:"codec"`
+	Channels int    `json:"channels,omitempty"`
+	Language string `json:"language,omitempty"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "gorm.io/gorm"
+
+// Tenant é uma organização cliente. Usuários, assets e arquivos de cada
+// tenant ficam isolados dos demais.
+type Tenant struct {
+	gorm.Model
+	Name         string `json:"name" gorm:"not null"`
+	Slug         string `json:"slug" gorm:"uniqueIndex;not null"`
+	StorageQuota int64  `json:"storage_quota"` // bytes; 0 = sem limite
+
+	ValidationRules ValidationRules `json:"validation_rules" gorm:"serializer:json;type:text"`
+}
+
+// ValidationRules são os limites adicionais que o tenant aplica aos uploads,
+// além das checagens de tipo e integridade feitas para todos
+type ValidationRules struct {
+	MaxSize            map[string]int64 `json:"max_size,omitempty"` // bytes por Content-Type
+	MaxPages           int              `json:"max_pages,omitempty"`
+	MaxDurationSeconds float64          `json:"max_duration_seconds,omitempty"`
+}
+
+const DefaultTenantSlug = "default"
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Estados de uma sessão de upload resumível
+const (
+	UploadInProgress = "in_progress"
+	// UploadCompleting: todos os bytes chegaram e o arquivo está sendo
+	// montado e registrado em segundo plano
+	UploadCompleting = "completing"
+	UploadCompleted  = "completed"
+	UploadFailed     = "failed"
+)
+
+// UploadSession acompanha um upload resumível em partes. Os bytes recebidos
+// ficam no backend de armazenamento como UploadChunk até que Offset alcance
+// Size; só então o Asset é criado. Error explica por que a conclusão falhou.
+type UploadSession struct {
+	gorm.Model
+	UploadID    string    `json:"upload_id" gorm:"uniqueIndex;not null"`
+	UserID      uint      `json:"user_id" gorm:"index"`
+	TenantID    uint      `json:"tenant_id" gorm:"index"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Offset      int64     `json:"offset"`
+	Checksum    string    `json:"checksum"`
+	Status      string    `json:"status" gorm:"default:in_progress"`
+	AssetID     *uint     `json:"asset_id"`
+	Error       string    `json:"error,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// UploadChunk é uma parte já gravada de uma UploadSession
+type UploadChunk struct {
+	ID       uint   `gorm:"primaryKey"`
+	UploadID string `gorm:"index;not null"`
+	Offset   int64  `gorm:"not null"`
+	Size     int64  `gorm:"not null"`
+	Key      string `gorm:"not null"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "gorm.io/gorm"
+
+type User struct {
+	gorm.Model
+	Name         string `json:"name"`
+	Email        string `json:"email" gorm:"uniqueIndex;not null"`
+	PasswordHash string `json:"-"`
+	Role         string `json:"role" gorm:"default:viewer"`
+	TenantID     uint   `json:"tenant_id" gorm:"index"`
+	ExternalID   string `json:"-" gorm:"index"`
+	TOTPSecret   string `json:"-"`
+	TOTPEnabled  bool   `json:"totp_enabled"`
+}
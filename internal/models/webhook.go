@@ -0,0 +1,73 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// Eventos que podem ser assinados por webhooks
+const (
+	// EventAssetUploaded: o upload terminou de ser verificado e o asset está disponível
+	EventAssetUploaded = "asset.uploaded"
+	// EventAssetFailed: o pós-processamento do upload falhou ou o asset foi para a quarentena
+	EventAssetFailed = "asset.failed"
+	// EventProcessingCompleted: a cópia com marca d'água está pronta para download
+	EventProcessingCompleted = "processing.completed"
+	// EventProcessingFailed: a marca d'água falhou em definitivo, sem novas tentativas
+	EventProcessingFailed = "processing.failed"
+)
+
+// WebhookEvents lista os eventos válidos
+var WebhookEvents = []string{EventAssetUploaded, EventAssetFailed, EventProcessingCompleted, EventProcessingFailed}
+
+// Webhook é um endpoint que recebe os eventos assinados. Webhooks de tenant
+// (criados por administradores) recebem os eventos de todos os usuários do
+// tenant; os demais, apenas os eventos do próprio usuário.
+type Webhook struct {
+	gorm.Model
+	TenantID    uint     `json:"tenant_id" gorm:"index;not null"`
+	UserID      uint     `json:"user_id" gorm:"index;not null"`
+	TenantWide  bool     `json:"tenant_wide"`
+	URL         string   `json:"url" gorm:"not null"`
+	Description string   `json:"description"`
+	Events      []string `json:"events" gorm:"serializer:json;type:text"`
+	// Secret assina os payloads com HMAC-SHA256; só é exibido na criação
+	Secret string `json:"-" gorm:"not null"`
+	Active bool   `json:"active" gorm:"default:true"`
+}
+
+// Subscribed indica se o webhook assina o evento
+func (w Webhook) Subscribed(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery é uma entrega de evento a um webhook, com o resultado da
+// última tentativa. As entregas pendentes são enviadas pelo dispatcher.
+type WebhookDelivery struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	WebhookID uint   `json:"webhook_id" gorm:"index;not null"`
+	EventID   string `json:"event_id" gorm:"index;not null"`
+	Event     string `json:"event"`
+	Payload   string `json:"payload" gorm:"type:text"`
+	// Status é pending, delivered ou failed (tentativas esgotadas)
+	Status         string     `json:"status" gorm:"index;default:pending"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	ResponseBody   string     `json:"response_body,omitempty" gorm:"type:text"`
+	Error          string     `json:"error,omitempty"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty" gorm:"index"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
@@ -7,11 +7,19 @@ import (
 
 const queueName = "asset-queue"
 
+// AssetJob pede o pós-processamento de um asset recém-enviado, cujo arquivo
+// já está em Path no backend de armazenamento
 type AssetJob struct {
-	ID           uint
-	Path         string
-	Type         string
-	TempFilePath string
+	ID   uint
+	Path string
+	Type string
+	// TempFilePath só é preenchido por jobs enfileirados antes do upload em
+	// streaming, quando o arquivo ainda precisava ser copiado
+	TempFilePath string `json:",omitempty"`
+	TenantID     uint
+	// Version é a versão do arquivo em Path; jobs de versões já substituídas
+	// são descartados. Zero em jobs anteriores ao versionamento.
+	Version int `json:",omitempty"`
 }
 
 func EnqueueAssetJob(job AssetJob) error {
@@ -22,3 +30,26 @@ func EnqueueAssetJob(job AssetJob) error {
 
 	return RedisClient.LPush(Ctx, queueName, data).Err()
 }
+
+// RemoveAssetJobs tira da fila os jobs de pós-processamento ainda não
+// iniciados do asset, retornando quantos foram removidos
+func RemoveAssetJobs(assetID uint) (int, error) {
+	entries, err := RedisClient.LRange(Ctx, queueName, 0, -1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		var job AssetJob
+		if err := json.Unmarshal([]byte(entry), &job); err != nil || job.ID != assetID {
+			continue
+		}
+		n, err := RedisClient.LRem(Ctx, queueName, 1, entry).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
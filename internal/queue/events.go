@@ -0,0 +1,66 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// JobEvent é uma mudança de status ou de progresso de um job de
+// processamento, publicada no canal do asset e do usuário que pediu o download
+type JobEvent struct {
+	// Type é o status do job (queued, processing, completed, failed) ou
+	// "progress"
+	Type         string    `json:"type"`
+	JobID        string    `json:"job_id"`
+	AssetID      string    `json:"asset_id"`
+	UserID       string    `json:"user_id"`
+	AssetVersion int       `json:"asset_version"`
+	Progress     float64   `json:"progress"`
+	Error        string    `json:"error,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// NewJobEvent cria um evento para o job
+func NewJobEvent(job *ProcessingJob, eventType string) JobEvent {
+	version := job.AssetVersion
+	if version == 0 {
+		version = 1
+	}
+	return JobEvent{
+		Type:         eventType,
+		JobID:        job.ID,
+		AssetID:      job.AssetID,
+		UserID:       job.UserID,
+		AssetVersion: version,
+		At:           time.Now(),
+	}
+}
+
+func assetEventsChannel(assetID, userID string) string {
+	return fmt.Sprintf("asset_events:%s:%s", assetID, userID)
+}
+
+// PublishJobEvent envia o evento a quem acompanha o asset; sem assinantes,
+// ele é descartado
+func (rq *RedisQueue) PublishJobEvent(event JobEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return rq.client.Publish(rq.ctx, assetEventsChannel(event.AssetID, event.UserID), payload).Err()
+}
+
+// SubscribeAssetEvents assina os eventos dos jobs do usuário para o asset.
+// A assinatura já está ativa quando a função retorna.
+func (rq *RedisQueue) SubscribeAssetEvents(ctx context.Context, assetID, userID string) (*redis.PubSub, error) {
+	pubsub := rq.client.Subscribe(ctx, assetEventsChannel(assetID, userID))
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, err
+	}
+	return pubsub, nil
+}
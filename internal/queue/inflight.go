@@ -0,0 +1,187 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// O processing_queue é consumido com entrega at-least-once: o BLMOVE move o
+// job atomicamente para a lista em andamento do consumidor, onde ele fica
+// até o Ack. Cada job em andamento tem um prazo em processingLeases, renovado
+// pelo heartbeat do worker; jobs com prazo vencido (processo derrubado,
+// ffmpeg morto) são devolvidos à fila por ReclaimExpired.
+const (
+	processingQueue    = "processing_queue"
+	inflightPrefix     = "processing_queue:inflight:"
+	processingLeases   = "processing_queue:leases" // ZSET payload -> prazo (ms)
+	processingOwners   = "processing_queue:owners" // HASH payload -> lista em andamento
+	reclaimBatchSize   = 100
+	dequeueBlockPeriod = 5 * time.Second
+
+	defaultVisibilityTimeout = 5 * time.Minute
+)
+
+// Delivery é um job retirado da fila e ainda não confirmado
+type Delivery struct {
+	Job      *ProcessingJob
+	payload  string
+	inflight string
+}
+
+// ErrNoJob indica que nenhum job chegou durante a espera do DequeueJob
+var ErrNoJob = errors.New("nenhum job disponível")
+
+// ErrInvalidJob indica que o payload retirado da fila nunca poderá ser
+// processado; a entrega retornada junto deve ser confirmada para descartá-lo
+var ErrInvalidJob = errors.New("job inválido na fila")
+
+// abandonedJobError é o LastError dos jobs devolvidos à fila por prazo vencido
+const abandonedJobError = "prazo de processamento expirado (worker interrompido)"
+
+// DequeueJob espera um job por alguns segundos e o registra como em
+// andamento para o consumidor, que deve ser único por worker e processo.
+// Retorna ErrNoJob quando a espera termina sem jobs, para que o worker possa
+// verificar se deve parar. Se o prazo não puder ser registrado, o job fica
+// na lista em andamento até o ReclaimExpired devolvê-lo à fila.
+func (rq *RedisQueue) DequeueJob(consumer string) (*Delivery, error) {
+	inflight := inflightPrefix + consumer
+
+	payload, err := rq.client.BLMove(rq.ctx, processingQueue, inflight, "RIGHT", "LEFT", dequeueBlockPeriod).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNoJob
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Um crash antes deste ponto deixa o job na lista sem prazo; o
+	// ReclaimExpired atribui um prazo a esses jobs
+	_, err = rq.client.TxPipelined(rq.ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(rq.ctx, processingLeases, redis.Z{Score: rq.deadline(), Member: payload})
+		pipe.HSet(rq.ctx, processingOwners, payload, inflight)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao registrar prazo do job: %v", err)
+	}
+
+	delivery := &Delivery{payload: payload, inflight: inflight}
+
+	var job ProcessingJob
+	if err := json.Unmarshal([]byte(payload), &job); err != nil {
+		return delivery, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	delivery.Job = &job
+	return delivery, nil
+}
+
+// Extend renova o prazo do job em andamento. Retorna false se o job já foi
+// devolvido à fila por ter excedido o prazo.
+func (rq *RedisQueue) Extend(d *Delivery) (bool, error) {
+	n, err := rq.client.ZAddXX(rq.ctx, processingLeases, redis.Z{Score: rq.deadline(), Member: d.payload}).Result()
+	if err != nil {
+		return false, err
+	}
+	if n > 0 {
+		return true, nil
+	}
+	// ZADD XX só conta inserções; o membro existir basta
+	_, err = rq.client.ZScore(rq.ctx, processingLeases, d.payload).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ackScript só remove o prazo se o job ainda pertence ao consumidor; se ele
+// foi devolvido à fila e entregue a outro worker, o prazo é do novo dono
+var ackScript = redis.NewScript(`
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+if redis.call('HGET', KEYS[3], ARGV[1]) == KEYS[1] then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('HDEL', KEYS[3], ARGV[1])
+end
+return 1
+`)
+
+// Ack confirma o job, removendo-o da lista em andamento do consumidor
+func (rq *RedisQueue) Ack(d *Delivery) error {
+	return ackScript.Run(rq.ctx, rq.client, []string{d.inflight, processingLeases, processingOwners}, d.payload).Err()
+}
+
+// reclaimScript devolve ao início da fila (lado consumido pelo BLMOVE) os
+// jobs com prazo vencido. A tentativa interrompida conta em attempts, com
+// ARGV[3] como último erro, para que um job que derruba o processo acabe na
+// fila de jobs mortos em vez de voltar para sempre.
+var reclaimScript = redis.NewScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, payload in ipairs(expired) do
+	local owner = redis.call('HGET', KEYS[2], payload)
+	if owner then
+		redis.call('LREM', owner, 1, payload)
+	end
+	local requeued = payload
+	local ok, job = pcall(cjson.decode, payload)
+	if ok and type(job) == 'table' then
+		job['attempts'] = (tonumber(job['attempts']) or 0) + 1
+		job['last_error'] = ARGV[3]
+		requeued = cjson.encode(job)
+	end
+	redis.call('RPUSH', KEYS[3], requeued)
+	redis.call('ZREM', KEYS[1], payload)
+	redis.call('HDEL', KEYS[2], payload)
+end
+return #expired
+`)
+
+// ReclaimExpired devolve à fila os jobs abandonados e retorna quantos foram
+// devolvidos
+func (rq *RedisQueue) ReclaimExpired() (int, error) {
+	if err := rq.leaseOrphans(); err != nil {
+		log.Printf("Erro ao verificar jobs em andamento sem prazo: %v", err)
+	}
+
+	total := 0
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	for {
+		n, err := reclaimScript.Run(rq.ctx, rq.client,
+			[]string{processingLeases, processingOwners, processingQueue}, now, reclaimBatchSize, abandonedJobError).Int()
+		total += n
+		if err != nil || n < reclaimBatchSize {
+			return total, err
+		}
+	}
+}
+
+// leaseOrphans dá um prazo aos jobs que estão em uma lista em andamento sem
+// prazo registrado, o que acontece se o processo cai logo após o BLMOVE
+func (rq *RedisQueue) leaseOrphans() error {
+	iter := rq.client.Scan(rq.ctx, 0, inflightPrefix+"*", 100).Iterator()
+	for iter.Next(rq.ctx) {
+		inflight := iter.Val()
+		payloads, err := rq.client.LRange(rq.ctx, inflight, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+		for _, payload := range payloads {
+			added, err := rq.client.ZAddNX(rq.ctx, processingLeases, redis.Z{Score: rq.deadline(), Member: payload}).Result()
+			if err != nil {
+				return err
+			}
+			if added > 0 {
+				rq.client.HSet(rq.ctx, processingOwners, payload, inflight)
+			}
+		}
+	}
+	return iter.Err()
+}
+
+func (rq *RedisQueue) deadline() float64 {
+	return float64(time.Now().Add(rq.VisibilityTimeout).UnixMilli())
+}
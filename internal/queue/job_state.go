@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// O estado de cada job de processamento fica no hash job_status:<id> por
+// jobStateTTL após a última atualização; depois disso só o ProcessedAsset
+// guarda o resultado.
+const jobStateTTL = 24 * time.Hour
+
+// ErrJobNotFound indica que não há estado para o job, seja porque ele não
+// existe ou porque expirou
+var ErrJobNotFound = errors.New("job não encontrado")
+
+// JobState é o estado de um job de processamento consultado por GET /jobs/:id
+type JobState struct {
+	ID           string     `json:"id"`
+	AssetID      string     `json:"asset_id"`
+	UserID       string     `json:"user_id"`
+	TenantID     uint       `json:"tenant_id"`
+	AssetVersion int        `json:"asset_version"`
+	Status       string     `json:"status"`
+	Progress     float64    `json:"progress"`
+	Attempts     int        `json:"attempts"`
+	MaxAttempts  int        `json:"max_attempts"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+	NextRetryAt  *time.Time `json:"next_retry_at,omitempty"`
+}
+
+func jobStateKey(jobID string) string {
+	return "job_status:" + jobID
+}
+
+// initJobState registra o job recém-enfileirado
+func (rq *RedisQueue) initJobState(pipe redis.Pipeliner, job ProcessingJob) {
+	key := jobStateKey(job.ID)
+	now := time.Now().Format(time.RFC3339Nano)
+	pipe.HSet(rq.ctx, key,
+		"id", job.ID,
+		"asset_id", job.AssetID,
+		"user_id", job.UserID,
+		"tenant_id", job.TenantID,
+		"asset_version", job.AssetVersion,
+		"status", "queued",
+		"progress", 0,
+		"attempts", job.Attempts,
+		"created_at", job.CreatedAt.Format(time.RFC3339Nano),
+		"updated_at", now,
+	)
+	pipe.Expire(rq.ctx, key, jobStateTTL)
+}
+
+// updateJobStateScript não recria o estado de um job expirado ou removido
+// junto com o asset
+var updateJobStateScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], unpack(ARGV, 2))
+redis.call('EXPIRE', KEYS[1], ARGV[1])
+return 1
+`)
+
+// updateJobState grava os campos informados se o job ainda tiver estado
+func (rq *RedisQueue) updateJobState(jobID string, values ...interface{}) error {
+	args := append([]interface{}{int(jobStateTTL.Seconds())}, values...)
+	args = append(args, "updated_at", time.Now().Format(time.RFC3339Nano))
+	return updateJobStateScript.Run(rq.ctx, rq.client, []string{jobStateKey(jobID)}, args...).Err()
+}
+
+func (rq *RedisQueue) SetJobStatus(jobID, status string) error {
+	now := time.Now().Format(time.RFC3339Nano)
+	values := []interface{}{"status", status}
+	switch status {
+	case "queued":
+		values = append(values, "finished_at", "")
+	case "processing":
+		values = append(values, "started_at", now, "finished_at", "", "next_retry_at", "", "progress", 0)
+	case "completed":
+		values = append(values, "finished_at", now, "progress", 100, "error", "")
+	case "failed":
+		values = append(values, "finished_at", now)
+	}
+	return rq.updateJobState(jobID, values...)
+}
+
+// SetJobAttempt registra a tentativa atual do job, o erro da anterior e,
+// se houver, quando será a próxima
+func (rq *RedisQueue) SetJobAttempt(jobID string, attempts int, lastError string, nextRetry time.Time) error {
+	retryAt := ""
+	if !nextRetry.IsZero() {
+		retryAt = nextRetry.Format(time.RFC3339Nano)
+	}
+	return rq.updateJobState(jobID, "attempts", attempts, "error", lastError, "next_retry_at", retryAt)
+}
+
+// SetJobProgress registra o percentual concluído do job
+func (rq *RedisQueue) SetJobProgress(jobID string, percent float64) error {
+	return rq.updateJobState(jobID, "progress", math.Round(percent*10)/10)
+}
+
+func (rq *RedisQueue) GetJobStatus(jobID string) (string, error) {
+	return rq.client.HGet(rq.ctx, jobStateKey(jobID), "status").Result()
+}
+
+// GetJob retorna o estado do job
+func (rq *RedisQueue) GetJob(jobID string) (JobState, error) {
+	fields, err := rq.client.HGetAll(rq.ctx, jobStateKey(jobID)).Result()
+	if err != nil {
+		return JobState{}, err
+	}
+	if len(fields) == 0 {
+		return JobState{}, ErrJobNotFound
+	}
+
+	state := JobState{
+		ID:          fields["id"],
+		AssetID:     fields["asset_id"],
+		UserID:      fields["user_id"],
+		Status:      fields["status"],
+		Error:       fields["error"],
+		MaxAttempts: rq.MaxAttempts,
+		CreatedAt:   parseJobTime(fields["created_at"]),
+		UpdatedAt:   parseJobTime(fields["updated_at"]),
+		StartedAt:   parseJobTime(fields["started_at"]),
+		FinishedAt:  parseJobTime(fields["finished_at"]),
+		NextRetryAt: parseJobTime(fields["next_retry_at"]),
+	}
+	tenantID, _ := strconv.ParseUint(fields["tenant_id"], 10, 64)
+	state.TenantID = uint(tenantID)
+	state.AssetVersion, _ = strconv.Atoi(fields["asset_version"])
+	state.Attempts, _ = strconv.Atoi(fields["attempts"])
+	state.Progress, _ = strconv.ParseFloat(fields["progress"], 64)
+	if state.AssetVersion == 0 {
+		state.AssetVersion = 1
+	}
+	return state, nil
+}
+
+func parseJobTime(value string) *time.Time {
+	if value == "" {
+		return nil
+	}
+	parsed, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil
+	}
+	return &parsed
+}
@@ -3,15 +3,22 @@ package queue
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"os"
 	"time"
 )
 
 type RedisQueue struct {
 	client *redis.Client
 	ctx    context.Context
+	// VisibilityTimeout é quanto um job pode ficar sem heartbeat antes de
+	// voltar para a fila
+	VisibilityTimeout time.Duration
+	// MaxAttempts é o total de tentativas antes de o job ir para a fila de
+	// jobs mortos; RetryBaseDelay é a espera após a primeira falha
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
 }
 
 type ProcessingJob struct {
@@ -21,15 +28,36 @@ type ProcessingJob struct {
 	AssetPath string    `json:"asset_path"`
 	AssetType string    `json:"asset_type"`
 	UserEmail string    `json:"user_email"`
+	TenantID  uint      `json:"tenant_id"`
 	CreatedAt time.Time `json:"created_at"`
+	// AssetVersion é a versão do arquivo em AssetPath; zero em jobs
+	// anteriores ao versionamento, tratados como versão 1
+	AssetVersion int `json:"asset_version,omitempty"`
+	// Attempts conta as tentativas já feitas e LastError guarda o erro da última
+	Attempts  int    `json:"attempts,omitempty"`
+	LastError string `json:"last_error,omitempty"`
 }
 
 func NewRedisQueue(redisAddr string) *RedisQueue {
 	log.Println("Connecting to Redis at", redisAddr)
 
+	timeout := defaultVisibilityTimeout
+	if value := os.Getenv("JOB_VISIBILITY_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			timeout = parsed
+		} else {
+			log.Printf("JOB_VISIBILITY_TIMEOUT inválido (%q), usando %s", value, timeout)
+		}
+	}
+
+	maxAttempts, retryBaseDelay := retryConfig()
+
 	return &RedisQueue{
-		client: RedisClient,
-		ctx:    Ctx,
+		client:            RedisClient,
+		ctx:               Ctx,
+		VisibilityTimeout: timeout,
+		MaxAttempts:       maxAttempts,
+		RetryBaseDelay:    retryBaseDelay,
 	}
 }
 
@@ -39,30 +67,71 @@ func (rq *RedisQueue) EnqueueJob(job ProcessingJob) error {
 		return err
 	}
 
-	return rq.client.LPush(rq.ctx, "processing_queue", jobJSON).Err()
+	_, err = rq.client.TxPipelined(rq.ctx, func(pipe redis.Pipeliner) error {
+		rq.initJobState(pipe, job)
+		pipe.LPush(rq.ctx, processingQueue, jobJSON)
+		return nil
+	})
+	return err
 }
 
-func (rq *RedisQueue) DequeueJob() (*ProcessingJob, error) {
-	result, err := rq.client.BRPop(rq.ctx, 0, "processing_queue").Result()
+// RemoveJobsForAsset tira da fila os jobs ainda não iniciados do asset,
+// inclusive os que aguardam nova tentativa ou estão mortos, e apaga os seus
+// status, retornando quantos foram removidos
+func (rq *RedisQueue) RemoveJobsForAsset(assetID string) (int, error) {
+	entries, err := rq.client.LRange(rq.ctx, processingQueue, 0, -1).Result()
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 
-	var job ProcessingJob
-	err = json.Unmarshal([]byte(result[1]), &job)
-	if err != nil {
-		return nil, err
+	removed := 0
+	for _, entry := range entries {
+		var job ProcessingJob
+		if err := json.Unmarshal([]byte(entry), &job); err != nil || job.AssetID != assetID {
+			continue
+		}
+		// Um worker pode ter retirado o job entre o LRANGE e o LREM
+		n, err := rq.client.LRem(rq.ctx, processingQueue, 1, entry).Result()
+		if err != nil {
+			return removed, err
+		}
+		if n > 0 {
+			removed++
+			rq.client.Del(rq.ctx, jobStateKey(job.ID))
+		}
 	}
 
-	return &job, nil
-}
-
-func (rq *RedisQueue) SetJobStatus(jobID, status string) error {
-	key := fmt.Sprintf("job_status:%s", jobID)
-	return rq.client.Set(rq.ctx, key, status, 24*time.Hour).Err()
-}
+	delayed, err := rq.client.ZRange(rq.ctx, delayedQueue, 0, -1).Result()
+	if err != nil {
+		return removed, err
+	}
+	for _, entry := range delayed {
+		var job ProcessingJob
+		if err := json.Unmarshal([]byte(entry), &job); err != nil || job.AssetID != assetID {
+			continue
+		}
+		n, err := rq.client.ZRem(rq.ctx, delayedQueue, entry).Result()
+		if err != nil {
+			return removed, err
+		}
+		if n > 0 {
+			removed++
+			rq.client.Del(rq.ctx, jobStateKey(job.ID))
+		}
+	}
 
-func (rq *RedisQueue) GetJobStatus(jobID string) (string, error) {
-	key := fmt.Sprintf("job_status:%s", jobID)
-	return rq.client.Get(rq.ctx, key).Result()
+	dead, err := rq.ListDeadJobs()
+	if err != nil {
+		return removed, err
+	}
+	for _, entry := range dead {
+		if entry.Job.AssetID != assetID {
+			continue
+		}
+		if err := rq.DiscardDeadJob(entry.Job.ID); err == nil {
+			removed++
+			rq.client.Del(rq.ctx, jobStateKey(entry.Job.ID))
+		}
+	}
+	return removed, nil
 }
@@ -0,0 +1,226 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Jobs que falham voltam para a fila após uma espera exponencial
+// (RetryBaseDelay, 2x, 4x..., limitada a maxRetryDelay) até MaxAttempts
+// tentativas; depois disso, ou em erros permanentes, vão para a fila de
+// jobs mortos, onde aguardam um administrador.
+const (
+	delayedQueue  = "processing_queue:delayed" // ZSET payload -> quando volta à fila (ms)
+	deadQueue     = "processing_queue:dead"    // HASH ID do job -> DeadJob
+	promoteBatch  = 100
+	maxRetryDelay = 30 * time.Minute
+
+	defaultMaxAttempts    = 5
+	defaultRetryBaseDelay = 30 * time.Second
+)
+
+// DeadJob é um job que esgotou as tentativas ou falhou de forma permanente
+type DeadJob struct {
+	Job       ProcessingJob `json:"job"`
+	Error     string        `json:"error"`
+	Permanent bool          `json:"permanent"`
+	FailedAt  time.Time     `json:"failed_at"`
+}
+
+// ErrDeadJobNotFound indica que o job não está na fila de jobs mortos
+var ErrDeadJobNotFound = errors.New("job não encontrado na fila de jobs mortos")
+
+// retryConfig lê JOB_MAX_ATTEMPTS e JOB_RETRY_BASE_DELAY
+func retryConfig() (int, time.Duration) {
+	attempts := defaultMaxAttempts
+	if value := os.Getenv("JOB_MAX_ATTEMPTS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			attempts = parsed
+		} else {
+			log.Printf("JOB_MAX_ATTEMPTS inválido (%q), usando %d", value, attempts)
+		}
+	}
+
+	delay := defaultRetryBaseDelay
+	if value := os.Getenv("JOB_RETRY_BASE_DELAY"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			delay = parsed
+		} else {
+			log.Printf("JOB_RETRY_BASE_DELAY inválido (%q), usando %s", value, delay)
+		}
+	}
+
+	return attempts, delay
+}
+
+// RetryDelay é a espera antes da próxima tentativa de um job que já falhou
+// attempts vezes
+func (rq *RedisQueue) RetryDelay(attempts int) time.Duration {
+	delay := rq.RetryBaseDelay
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+	return delay
+}
+
+// settleScript confirma o job como o ackScript e o grava em KEYS[4]: com
+// ARGV[3] = "zset", na fila de espera com score ARGV[4]; com "hash", na fila
+// de jobs mortos sob a chave ARGV[4]. ARGV[2] é o payload atualizado.
+var settleScript = redis.NewScript(`
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+if redis.call('HGET', KEYS[3], ARGV[1]) == KEYS[1] then
+	redis.call('ZREM', KEYS[2], ARGV[1])
+	redis.call('HDEL', KEYS[3], ARGV[1])
+end
+if ARGV[3] == 'zset' then
+	redis.call('ZADD', KEYS[4], ARGV[4], ARGV[2])
+else
+	redis.call('HSET', KEYS[4], ARGV[4], ARGV[2])
+end
+return 1
+`)
+
+// Retry confirma a entrega e agenda uma nova tentativa do job, que já deve
+// ter Attempts e LastError atualizados
+func (rq *RedisQueue) Retry(d *Delivery, delay time.Duration) error {
+	payload, err := json.Marshal(d.Job)
+	if err != nil {
+		return err
+	}
+	at := strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10)
+	return settleScript.Run(rq.ctx, rq.client,
+		[]string{d.inflight, processingLeases, processingOwners, delayedQueue},
+		d.payload, payload, "zset", at).Err()
+}
+
+// DeadLetter confirma a entrega e move o job para a fila de jobs mortos
+func (rq *RedisQueue) DeadLetter(d *Delivery, cause error, permanent bool) error {
+	payload, err := json.Marshal(DeadJob{
+		Job:       *d.Job,
+		Error:     cause.Error(),
+		Permanent: permanent,
+		FailedAt:  time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return settleScript.Run(rq.ctx, rq.client,
+		[]string{d.inflight, processingLeases, processingOwners, deadQueue},
+		d.payload, payload, "hash", d.Job.ID).Err()
+}
+
+var promoteScript = redis.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+for _, payload in ipairs(due) do
+	redis.call('LPUSH', KEYS[2], payload)
+	redis.call('ZREM', KEYS[1], payload)
+end
+return #due
+`)
+
+// PromoteDue devolve à fila os jobs cuja espera terminou
+func (rq *RedisQueue) PromoteDue() (int, error) {
+	total := 0
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	for {
+		n, err := promoteScript.Run(rq.ctx, rq.client, []string{delayedQueue, processingQueue}, now, promoteBatch).Int()
+		total += n
+		if err != nil || n < promoteBatch {
+			return total, err
+		}
+	}
+}
+
+// ListDeadJobs lista os jobs mortos, do mais recente para o mais antigo
+func (rq *RedisQueue) ListDeadJobs() ([]DeadJob, error) {
+	entries, err := rq.client.HGetAll(rq.ctx, deadQueue).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs := make([]DeadJob, 0, len(entries))
+	for id, entry := range entries {
+		var dead DeadJob
+		if err := json.Unmarshal([]byte(entry), &dead); err != nil {
+			log.Printf("Job morto %s inválido: %v", id, err)
+			continue
+		}
+		jobs = append(jobs, dead)
+	}
+
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].FailedAt.After(jobs[j].FailedAt) })
+	return jobs, nil
+}
+
+// GetDeadJob busca um job morto pelo ID
+func (rq *RedisQueue) GetDeadJob(jobID string) (DeadJob, error) {
+	var dead DeadJob
+	entry, err := rq.client.HGet(rq.ctx, deadQueue, jobID).Result()
+	if errors.Is(err, redis.Nil) {
+		return dead, ErrDeadJobNotFound
+	}
+	if err != nil {
+		return dead, err
+	}
+	err = json.Unmarshal([]byte(entry), &dead)
+	return dead, err
+}
+
+var requeueDeadScript = redis.NewScript(`
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('LPUSH', KEYS[2], ARGV[2])
+return 1
+`)
+
+// RequeueDeadJob devolve o job morto à fila com as tentativas zeradas
+func (rq *RedisQueue) RequeueDeadJob(jobID string) (ProcessingJob, error) {
+	dead, err := rq.GetDeadJob(jobID)
+	if err != nil {
+		return dead.Job, err
+	}
+
+	job := dead.Job
+	job.Attempts = 0
+	job.LastError = ""
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return job, err
+	}
+
+	// Outro administrador pode ter reenfileirado ou descartado o job
+	n, err := requeueDeadScript.Run(rq.ctx, rq.client, []string{deadQueue, processingQueue}, jobID, payload).Int()
+	if err != nil {
+		return job, err
+	}
+	if n == 0 {
+		return job, ErrDeadJobNotFound
+	}
+
+	rq.SetJobStatus(job.ID, "queued")
+	rq.SetJobAttempt(job.ID, 0, "", time.Time{})
+	return job, nil
+}
+
+// DiscardDeadJob remove o job da fila de jobs mortos
+func (rq *RedisQueue) DiscardDeadJob(jobID string) error {
+	n, err := rq.client.HDel(rq.ctx, deadQueue, jobID).Result()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrDeadJobNotFound
+	}
+	return nil
+}
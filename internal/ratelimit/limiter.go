@@ -0,0 +1,115 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"projeto_drm/poc/internal/queue"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Policy descreve um token bucket: Capacity requisições em rajada,
+// reabastecidas continuamente ao longo de Period
+type Policy struct {
+	Name     string
+	Capacity int
+	Period   time.Duration
+}
+
+// Result é o resultado de uma tentativa de consumir um token
+type Result struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// O bucket fica em um hash (tokens, ts) e é atualizado atomicamente. O
+// relógio do Redis é usado para que várias instâncias da API compartilhem a
+// mesma referência de tempo.
+var tokenBucket = redis.NewScript(`
+local capacity = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1]) or capacity
+local ts = tonumber(data[2]) or now
+
+local rate = capacity / period
+tokens = math.min(capacity, tokens + (now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+  allowed = 1
+else
+  retry = math.ceil((1 - tokens) / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], period)
+return {allowed, math.floor(tokens), retry}
+`)
+
+// Allow tenta consumir um token do bucket identificado por key
+func Allow(policy Policy, key string) (Result, error) {
+	redisKey := fmt.Sprintf("ratelimit:%s:%s", policy.Name, key)
+	values, err := tokenBucket.Run(queue.Ctx, queue.RedisClient, []string{redisKey},
+		policy.Capacity, policy.Period.Milliseconds()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+// policyFromEnv lê a política no formato "capacidade/período" (ex.: 10/1m),
+// mantendo o valor padrão quando a variável não está definida ou é inválida
+func policyFromEnv(policy Policy, envKey string) Policy {
+	value := os.Getenv(envKey)
+	if value == "" {
+		return policy
+	}
+
+	parts := strings.SplitN(value, "/", 2)
+	if len(parts) == 2 {
+		c, errC := strconv.Atoi(strings.TrimSpace(parts[0]))
+		p, errP := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if errC == nil && errP == nil && c > 0 && p > 0 {
+			policy.Capacity = c
+			policy.Period = p
+			return policy
+		}
+	}
+
+	log.Printf("Valor inválido para %s (%q), usando %d/%s", envKey, value, policy.Capacity, policy.Period)
+	return policy
+}
+
+func lockoutFromEnv(lockout Lockout, failuresKey, durationKey string) Lockout {
+	if value := os.Getenv(failuresKey); value != "" {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil && n > 0 {
+			lockout.MaxFailures = n
+		} else {
+			log.Printf("Valor inválido para %s (%q)", failuresKey, value)
+		}
+	}
+	if value := os.Getenv(durationKey); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			lockout.Duration = d
+		} else {
+			log.Printf("Valor inválido para %s (%q)", durationKey, value)
+		}
+	}
+	return lockout
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"fmt"
+	"projeto_drm/poc/internal/queue"
+	"time"
+)
+
+// Lockout bloqueia uma chave (ex.: uma conta) após MaxFailures falhas
+// consecutivas dentro de Duration, pelo mesmo Duration
+type Lockout struct {
+	Name        string
+	MaxFailures int64
+	Duration    time.Duration
+}
+
+func (l Lockout) failuresKey(key string) string {
+	return fmt.Sprintf("ratelimit:%s:failures:%s", l.Name, key)
+}
+
+func (l Lockout) lockedKey(key string) string {
+	return fmt.Sprintf("ratelimit:%s:locked:%s", l.Name, key)
+}
+
+// Locked retorna quanto tempo falta para a chave ser desbloqueada (zero se livre)
+func (l Lockout) Locked(key string) (time.Duration, error) {
+	ttl, err := queue.RedisClient.PTTL(queue.Ctx, l.lockedKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Fail registra uma falha e bloqueia a chave ao atingir o limite
+func (l Lockout) Fail(key string) (time.Duration, error) {
+	pipe := queue.RedisClient.TxPipeline()
+	count := pipe.Incr(queue.Ctx, l.failuresKey(key))
+	pipe.Expire(queue.Ctx, l.failuresKey(key), l.Duration)
+	if _, err := pipe.Exec(queue.Ctx); err != nil {
+		return 0, err
+	}
+
+	if count.Val() < l.MaxFailures {
+		return 0, nil
+	}
+
+	pipe = queue.RedisClient.TxPipeline()
+	pipe.Set(queue.Ctx, l.lockedKey(key), 1, l.Duration)
+	pipe.Del(queue.Ctx, l.failuresKey(key))
+	if _, err := pipe.Exec(queue.Ctx); err != nil {
+		return 0, err
+	}
+	return l.Duration, nil
+}
+
+// Reset limpa as falhas registradas após um acesso bem-sucedido
+func (l Lockout) Reset(key string) error {
+	return queue.RedisClient.Del(queue.Ctx, l.failuresKey(key)).Err()
+}
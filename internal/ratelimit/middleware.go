@@ -0,0 +1,57 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyFunc extrai da requisição a identidade usada no bucket
+type KeyFunc func(c *gin.Context) string
+
+// ByIP limita por endereço IP do cliente
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// Middleware aplica a política ao grupo de rotas. Em caso de falha do Redis
+// a requisição segue (fail open) para não derrubar a API junto com o cache.
+func Middleware(policy Policy, keyFunc KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		result, err := Allow(policy, keyFunc(c))
+		if err != nil {
+			log.Printf("Erro no rate limit %s: %v", policy.Name, err)
+			c.Next()
+			return
+		}
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(policy.Capacity))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+		if !result.Allowed {
+			TooManyRequests(c, result.RetryAfter)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// TooManyRequests responde 429 com o header Retry-After em segundos
+func TooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "Muitas requisições. Tente novamente mais tarde.",
+		"retry_after": seconds,
+	})
+	c.Abort()
+}
@@ -0,0 +1,28 @@
+package ratelimit
+
+import "time"
+
+var (
+	// LoginByIP limita tentativas de login por endereço IP
+	LoginByIP = Policy{Name: "login-ip", Capacity: 20, Period: time.Minute}
+	// Download limita pedidos de download (e de processamento) por usuário
+	Download = Policy{Name: "download", Capacity: 30, Period: time.Minute}
+	// Upload limita envios de arquivo por usuário
+	Upload = Policy{Name: "upload", Capacity: 10, Period: time.Minute}
+
+	// LoginAccount bloqueia a conta após falhas consecutivas de login
+	LoginAccount = Lockout{
+		Name:        "login-account",
+		MaxFailures: 5,
+		Duration:    15 * time.Minute,
+	}
+)
+
+// Init aplica as políticas configuradas no ambiente. Deve ser chamado antes
+// do registro das rotas.
+func Init() {
+	LoginByIP = policyFromEnv(LoginByIP, "RATE_LIMIT_LOGIN_IP")
+	Download = policyFromEnv(Download, "RATE_LIMIT_DOWNLOAD")
+	Upload = policyFromEnv(Upload, "RATE_LIMIT_UPLOAD")
+	LoginAccount = lockoutFromEnv(LoginAccount, "LOGIN_MAX_FAILURES", "LOGIN_LOCKOUT")
+}
@@ -0,0 +1,126 @@
+package scanner
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize é o tamanho de cada bloco enviado no INSTREAM
+const clamdChunkSize = 64 << 10
+
+// Clamd fala o protocolo TCP do daemon do ClamAV usando o comando INSTREAM:
+// o arquivo é enviado em blocos prefixados pelo tamanho (uint32 big-endian),
+// terminando com um bloco vazio, e o daemon responde "stream: OK" ou
+// "stream: <assinatura> FOUND".
+type Clamd struct {
+	address string
+	timeout time.Duration
+}
+
+func NewClamd(address string, timeout time.Duration) *Clamd {
+	return &Clamd{address: address, timeout: timeout}
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("clamd: erro ao iniciar INSTREAM: %w", err)
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return Result{}, fmt.Errorf("clamd: erro ao enviar arquivo: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return Result{}, fmt.Errorf("clamd: erro ao enviar arquivo: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return Result{}, readErr
+		}
+	}
+
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return Result{}, fmt.Errorf("clamd: erro ao finalizar INSTREAM: %w", err)
+	}
+
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("clamd: erro ao ler resposta: %w", err)
+	}
+	return parseClamdReply(reply)
+}
+
+// Ping confirma que o daemon está respondendo
+func (c *Clamd) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return err
+	}
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if strings.TrimRight(reply, "\x00") != "PONG" {
+		return fmt.Errorf("clamd: resposta inesperada ao PING: %q", reply)
+	}
+	return nil
+}
+
+func (c *Clamd) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: 10 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", c.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: erro ao conectar em %s: %w", c.address, err)
+	}
+
+	deadline := time.Now().Add(c.timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	conn.SetDeadline(deadline)
+	return conn, nil
+}
+
+// parseClamdReply interpreta respostas como "stream: OK",
+// "stream: Eicar-Signature FOUND" ou "INSTREAM size limit exceeded. ERROR"
+func parseClamdReply(reply string) (Result, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	status := strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	case strings.HasSuffix(status, " ERROR"):
+		return Result{}, fmt.Errorf("clamd: %s", strings.TrimSuffix(status, " ERROR"))
+	default:
+		return Result{}, fmt.Errorf("clamd: resposta inesperada: %q", reply)
+	}
+}
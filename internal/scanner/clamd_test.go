@@ -0,0 +1,252 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClamd é um daemon local que fala o protocolo INSTREAM do clamd e
+// responde com reply(conteúdo recebido). Com maxStream > 0 responde como o
+// clamd ao ultrapassar StreamMaxLength; com hang não responde.
+type fakeClamd struct {
+	listener  net.Listener
+	reply     func(data []byte) string
+	maxStream int
+	hang      bool
+
+	mu       sync.Mutex
+	commands []string
+	received [][]byte
+}
+
+func newFakeClamd(t *testing.T, reply func(data []byte) string) *fakeClamd {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("abrir porta: %v", err)
+	}
+	f := &fakeClamd{listener: listener, reply: reply}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeClamd) addr() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeClamd) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	command = strings.TrimSuffix(command, "\x00")
+	f.mu.Lock()
+	f.commands = append(f.commands, command)
+	f.mu.Unlock()
+
+	switch command {
+	case "zPING":
+		io.WriteString(conn, "PONG\x00")
+		return
+	case "zINSTREAM":
+	default:
+		io.WriteString(conn, "UNKNOWN COMMAND\x00")
+		return
+	}
+
+	var data bytes.Buffer
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, size); err != nil {
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, r, int64(n)); err != nil {
+			return
+		}
+		if f.maxStream > 0 && data.Len() > f.maxStream {
+			io.WriteString(conn, "INSTREAM size limit exceeded. ERROR\x00")
+			// Consome o resto para o cliente não receber um reset antes de ler a resposta
+			io.Copy(io.Discard, r)
+			return
+		}
+	}
+
+	f.mu.Lock()
+	f.received = append(f.received, data.Bytes())
+	f.mu.Unlock()
+
+	if f.hang {
+		io.Copy(io.Discard, r)
+		return
+	}
+	io.WriteString(conn, f.reply(data.Bytes())+"\x00")
+}
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+
+	data := make([]byte, n)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("gerar dados: %v", err)
+	}
+	return data
+}
+
+func TestClamdScanClean(t *testing.T) {
+	daemon := newFakeClamd(t, func([]byte) string { return "stream: OK" })
+	// Maior que um bloco, para exercitar a divisão em partes
+	data := randomBytes(t, 3*clamdChunkSize+123)
+
+	result, err := NewClamd(daemon.addr(), time.Second).Scan(context.Background(), bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if result.Infected || result.Signature != "" {
+		t.Fatalf("resultado = %+v", result)
+	}
+
+	daemon.mu.Lock()
+	defer daemon.mu.Unlock()
+	if len(daemon.commands) != 1 || daemon.commands[0] != "zINSTREAM" {
+		t.Errorf("comandos = %v", daemon.commands)
+	}
+	if len(daemon.received) != 1 || !bytes.Equal(daemon.received[0], data) {
+		t.Error("o daemon não recebeu o arquivo completo")
+	}
+}
+
+func TestClamdScanInfected(t *testing.T) {
+	eicar := []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+	daemon := newFakeClamd(t, func(data []byte) string {
+		if bytes.Contains(data, []byte("EICAR-STANDARD-ANTIVIRUS-TEST-FILE")) {
+			return "stream: Win.Test.EICAR_HDB-1 FOUND"
+		}
+		return "stream: OK"
+	})
+
+	result, err := NewClamd(daemon.addr(), time.Second).Scan(context.Background(), bytes.NewReader(eicar))
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if !result.Infected || result.Signature != "Win.Test.EICAR_HDB-1" {
+		t.Fatalf("resultado = %+v", result)
+	}
+}
+
+func TestClamdScanErrorReply(t *testing.T) {
+	daemon := newFakeClamd(t, func([]byte) string { return "stream: Can't allocate memory ERROR" })
+
+	_, err := NewClamd(daemon.addr(), time.Second).Scan(context.Background(), strings.NewReader("conteúdo"))
+	if err == nil || !strings.Contains(err.Error(), "Can't allocate memory") {
+		t.Fatalf("erro = %v", err)
+	}
+}
+
+func TestClamdScanSizeLimit(t *testing.T) {
+	daemon := newFakeClamd(t, func([]byte) string { return "stream: OK" })
+	daemon.maxStream = clamdChunkSize
+
+	_, err := NewClamd(daemon.addr(), time.Second).Scan(context.Background(), bytes.NewReader(randomBytes(t, 4*clamdChunkSize)))
+	if err == nil || !strings.Contains(err.Error(), "size limit exceeded") {
+		t.Fatalf("erro = %v", err)
+	}
+}
+
+func TestClamdScanTimeout(t *testing.T) {
+	daemon := newFakeClamd(t, nil)
+	daemon.hang = true
+
+	start := time.Now()
+	_, err := NewClamd(daemon.addr(), 200*time.Millisecond).Scan(context.Background(), strings.NewReader("conteúdo"))
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Fatalf("erro = %v, esperado timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("timeout levou %s", elapsed)
+	}
+
+	// O prazo do contexto vale quando é menor que o do cliente
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start = time.Now()
+	if _, err := NewClamd(daemon.addr(), time.Minute).Scan(ctx, strings.NewReader("conteúdo")); err == nil {
+		t.Fatal("Scan sem resposta do daemon não falhou")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("prazo do contexto ignorado: %s", elapsed)
+	}
+}
+
+func TestClamdUnavailable(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("abrir porta: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+
+	if _, err := NewClamd(addr, time.Second).Scan(context.Background(), strings.NewReader("x")); err == nil {
+		t.Fatal("Scan sem daemon não falhou")
+	}
+}
+
+func TestClamdPing(t *testing.T) {
+	daemon := newFakeClamd(t, nil)
+
+	if err := NewClamd(daemon.addr(), time.Second).Ping(context.Background()); err != nil {
+		t.Fatalf("Ping: %v", err)
+	}
+}
+
+func TestParseClamdReply(t *testing.T) {
+	tests := []struct {
+		reply     string
+		infected  bool
+		signature string
+		wantErr   bool
+	}{
+		{"stream: OK\x00", false, "", false},
+		{"stream: Eicar-Signature FOUND\x00", true, "Eicar-Signature", false},
+		{"stream: Multi Word Signature FOUND\n", true, "Multi Word Signature", false},
+		{"INSTREAM size limit exceeded. ERROR\x00", false, "", true},
+		{"stream: lstat() failed ERROR", false, "", true},
+		{"", false, "", true},
+		{"PONG", false, "", true},
+	}
+
+	for _, tt := range tests {
+		result, err := parseClamdReply(tt.reply)
+		if (err != nil) != tt.wantErr || result.Infected != tt.infected || result.Signature != tt.signature {
+			t.Errorf("parseClamdReply(%q) = %+v, %v", tt.reply, result, err)
+		}
+	}
+}
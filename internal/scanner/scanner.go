@@ -0,0 +1,58 @@
+package scanner
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// Result é o veredito de uma varredura
+type Result struct {
+	Infected  bool
+	Signature string // nome da ameaça quando Infected
+}
+
+// Scanner verifica o conteúdo de um arquivo em busca de malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Default é o scanner configurado por Init
+var Default Scanner = Noop{}
+
+// Init configura o scanner padrão. Com CLAMD_ADDRESS (ex.: "clamav:3310") os
+// arquivos são enviados ao clamd; sem ele nenhuma varredura é feita.
+func Init() {
+	address := os.Getenv("CLAMD_ADDRESS")
+	if address == "" {
+		log.Println("CLAMD_ADDRESS não definido, uploads não passarão por antivírus")
+		Default = Noop{}
+		return
+	}
+
+	timeout := 5 * time.Minute
+	if value := os.Getenv("CLAMD_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			timeout = parsed
+		} else {
+			log.Printf("CLAMD_TIMEOUT inválido (%q), usando %s", value, timeout)
+		}
+	}
+
+	clamd := NewClamd(address, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := clamd.Ping(ctx); err != nil {
+		log.Printf("clamd indisponível em %s: %v", address, err)
+	}
+	Default = clamd
+}
+
+// Noop aprova todos os arquivos
+type Noop struct{}
+
+func (Noop) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	return Result{}, nil
+}
@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Local guarda os objetos em disco, abaixo do diretório raiz
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	return &Local{root: root}
+}
+
+// path converte a chave em caminho no disco, sem permitir sair da raiz
+func (l *Local) path(key string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	if clean == string(filepath.Separator) {
+		return "", fmt.Errorf("chave inválida: %q", key)
+	}
+	return filepath.Join(l.root, clean), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	// Escreve em um arquivo temporário e renomeia, para que leitores nunca
+	// vejam um arquivo pela metade
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(path)),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Move(ctx context.Context, src, dst string) error {
+	srcPath, err := l.path(src)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(srcPath); errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return l.moveFile(srcPath, dst)
+}
+
+func (l *Local) PresignGet(ctx context.Context, key string, expiry time.Duration, filename string) (string, error) {
+	return "", ErrPresignUnsupported
+}
+
+// moveFile move um arquivo local para a chave, copiando quando o rename não
+// é possível (por exemplo, entre sistemas de arquivos diferentes)
+func (l *Local) moveFile(src, key string) error {
+	dst, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := l.Put(context.Background(), key, f, -1, ""); err != nil {
+		return err
+	}
+
+	f.Close()
+	return os.Remove(src)
+}
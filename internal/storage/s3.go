@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 guarda os objetos em um bucket compatível com S3 (AWS, MinIO, etc.)
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3FromEnv configura o backend com S3_ENDPOINT, S3_BUCKET, S3_REGION,
+// S3_ACCESS_KEY, S3_SECRET_KEY e S3_USE_SSL, criando o bucket se necessário
+func NewS3FromEnv() (*S3, error) {
+	endpoint := os.Getenv("S3_ENDPOINT")
+	bucket := os.Getenv("S3_BUCKET")
+	if endpoint == "" || bucket == "" {
+		return nil, errors.New("S3_ENDPOINT e S3_BUCKET são obrigatórios para STORAGE_TYPE=s3")
+	}
+
+	return NewS3(endpoint, bucket, os.Getenv("S3_REGION"),
+		os.Getenv("S3_ACCESS_KEY"), os.Getenv("S3_SECRET_KEY"), os.Getenv("S3_USE_SSL") == "true")
+}
+
+func NewS3(endpoint, bucket, region, accessKey, secretKey string, useSSL bool) (*S3, error) {
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure: useSSL,
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao criar cliente S3: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	exists, err := client.BucketExists(ctx, bucket)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao verificar bucket %s: %v", bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{Region: region}); err != nil {
+			return nil, fmt.Errorf("erro ao criar bucket %s: %v", bucket, err)
+		}
+	}
+
+	return &S3{client: client, bucket: bucket}, nil
+}
+
+// streamPartSize é o tamanho das partes do multipart quando o tamanho total
+// não é conhecido; sem ele o minio-go reserva partes de centenas de MB
+const streamPartSize = 16 << 20
+
+func (s *S3) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if size < 0 {
+		opts.PartSize = streamPartSize
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, size, opts)
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	// GetObject é preguiçoso; o Stat confirma a existência antes de devolver
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, translateS3Error(err)
+	}
+	if _, err := obj.Stat(); err != nil {
+		obj.Close()
+		return nil, translateS3Error(err)
+	}
+	return obj, nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return ObjectInfo{}, translateS3Error(err)
+	}
+
+	return ObjectInfo{
+		Key:         key,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModTime:     info.LastModified,
+	}, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	return translateS3Error(s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{}))
+}
+
+// Move copia no próprio servidor (ComposeObject aceita objetos acima de 5GB)
+// e remove a origem
+func (s *S3) Move(ctx context.Context, src, dst string) error {
+	_, err := s.client.ComposeObject(ctx,
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dst},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: src},
+	)
+	if err != nil {
+		return translateS3Error(err)
+	}
+	return s.Delete(ctx, src)
+}
+
+func (s *S3) PresignGet(ctx context.Context, key string, expiry time.Duration, filename string) (string, error) {
+	params := url.Values{}
+	if filename != "" {
+		params.Set("response-content-disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	}
+
+	u, err := s.client.PresignedGetObject(ctx, s.bucket, key, expiry, params)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+func translateS3Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
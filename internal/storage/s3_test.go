@@ -0,0 +1,459 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeS3 é um servidor local com o subconjunto da API S3 usado pelo minio-go
+// neste backend: buckets, objetos, cópia no servidor e multipart. A
+// autenticação não é verificada.
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+	uploads map[string]map[int][]byte
+	nextID  int
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func newFakeS3(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	f := &fakeS3{buckets: map[string]map[string]fakeObject{}, uploads: map[string]map[int][]byte{}}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestS3(t *testing.T) (*S3, *httptest.Server) {
+	t.Helper()
+
+	server := newFakeS3(t)
+	s3, err := NewS3(strings.TrimPrefix(server.URL, "http://"), "drm-test", "us-east-1", "access", "secret", false)
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	return s3, server
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	query := r.URL.Query()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	objects, exists := f.buckets[bucket]
+	if key == "" {
+		switch {
+		case r.Method == http.MethodGet && query.Has("location"):
+			writeXML(w, http.StatusOK, struct {
+				XMLName xml.Name `xml:"LocationConstraint"`
+			}{})
+		case r.Method == http.MethodHead && exists:
+			w.WriteHeader(http.StatusOK)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		case r.Method == http.MethodPut:
+			f.buckets[bucket] = map[string]fakeObject{}
+			w.WriteHeader(http.StatusOK)
+		default:
+			s3Error(w, http.StatusNotImplemented, "NotImplemented", bucket, key)
+		}
+		return
+	}
+	if !exists {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket", bucket, key)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && query.Has("uploads"):
+		f.nextID++
+		uploadID := strconv.Itoa(f.nextID)
+		f.uploads[uploadID] = map[int][]byte{}
+		writeXML(w, http.StatusOK, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			Bucket   string
+			Key      string
+			UploadId string
+		}{Bucket: bucket, Key: key, UploadId: uploadID})
+
+	case r.Method == http.MethodPut && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload", bucket, key)
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			// UploadPartCopy, usado pelo ComposeObject
+			object, ok := f.copySource(r)
+			if !ok {
+				s3Error(w, http.StatusNotFound, "NoSuchKey", bucket, key)
+				return
+			}
+			parts[number] = object.data
+			writeXML(w, http.StatusOK, struct {
+				XMLName      xml.Name `xml:"CopyPartResult"`
+				ETag         string
+				LastModified string
+			}{ETag: etag(object.data), LastModified: time.Now().UTC().Format(time.RFC3339)})
+			return
+		}
+		data, err := readPayload(r)
+		if err != nil {
+			s3Error(w, http.StatusBadRequest, "IncompleteBody", bucket, key)
+			return
+		}
+		parts[number] = data
+		w.Header().Set("ETag", etag(data))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodPost && query.Has("uploadId"):
+		parts, ok := f.uploads[query.Get("uploadId")]
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchUpload", bucket, key)
+			return
+		}
+		var complete struct {
+			Parts []struct {
+				PartNumber int
+			} `xml:"Part"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&complete); err != nil {
+			s3Error(w, http.StatusBadRequest, "MalformedXML", bucket, key)
+			return
+		}
+		sort.Slice(complete.Parts, func(i, j int) bool { return complete.Parts[i].PartNumber < complete.Parts[j].PartNumber })
+		var data bytes.Buffer
+		for _, part := range complete.Parts {
+			data.Write(parts[part.PartNumber])
+		}
+		delete(f.uploads, query.Get("uploadId"))
+		objects[key] = fakeObject{data: data.Bytes(), contentType: r.Header.Get("Content-Type"), modTime: time.Now()}
+		writeXML(w, http.StatusOK, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: bucket, Key: key, ETag: etag(data.Bytes())})
+
+	case r.Method == http.MethodDelete && query.Has("uploadId"):
+		delete(f.uploads, query.Get("uploadId"))
+		w.WriteHeader(http.StatusNoContent)
+
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		object, ok := f.copySource(r)
+		if !ok {
+			s3Error(w, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		object.modTime = time.Now()
+		objects[key] = object
+		writeXML(w, http.StatusOK, struct {
+			XMLName      xml.Name `xml:"CopyObjectResult"`
+			ETag         string
+			LastModified string
+		}{ETag: etag(object.data), LastModified: object.modTime.UTC().Format(time.RFC3339)})
+
+	case r.Method == http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			s3Error(w, http.StatusBadRequest, "IncompleteBody", bucket, key)
+			return
+		}
+		objects[key] = fakeObject{data: data, contentType: r.Header.Get("Content-Type"), modTime: time.Now()}
+		w.Header().Set("ETag", etag(data))
+		w.WriteHeader(http.StatusOK)
+
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		object, ok := objects[key]
+		if !ok {
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			s3Error(w, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		w.Header().Set("ETag", etag(object.data))
+		w.Header().Set("Content-Type", object.contentType)
+		if disposition := query.Get("response-content-disposition"); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		http.ServeContent(w, r, key, object.modTime, bytes.NewReader(object.data))
+
+	case r.Method == http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		s3Error(w, http.StatusNotImplemented, "NotImplemented", bucket, key)
+	}
+}
+
+// copySource devolve o objeto indicado em x-amz-copy-source
+func (f *fakeS3) copySource(r *http.Request) (fakeObject, bool) {
+	source, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+	source, _, _ = strings.Cut(source, "?")
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(source, "/"), "/")
+	object, ok := f.buckets[bucket][key]
+	return object, ok
+}
+
+// readPayload lê o corpo da requisição, decodificando o formato aws-chunked
+// usado pelo minio-go em conexões sem TLS
+func readPayload(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(r.Body)
+	}
+
+	var data bytes.Buffer
+	reader := bufio.NewReader(r.Body)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		sizeHex, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		size, err := strconv.ParseInt(sizeHex, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if size == 0 {
+			// O restante são trailers opcionais
+			io.Copy(io.Discard, reader)
+			return data.Bytes(), nil
+		}
+		if _, err := io.CopyN(&data, reader, size); err != nil {
+			return nil, err
+		}
+		if _, err := reader.Discard(2); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func etag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func writeXML(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(v)
+}
+
+func s3Error(w http.ResponseWriter, status int, code, bucket, key string) {
+	writeXML(w, status, struct {
+		XMLName    xml.Name `xml:"Error"`
+		Code       string
+		Message    string
+		BucketName string
+		Key        string
+		RequestId  string
+	}{Code: code, Message: code, BucketName: bucket, Key: key, RequestId: "fake"})
+}
+
+func readObject(t *testing.T, s Storage, key string) []byte {
+	t.Helper()
+
+	r, err := s.Get(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Get(%s): %v", key, err)
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ler %s: %v", key, err)
+	}
+	return data
+}
+
+func TestS3PutGetStat(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+	data := []byte("%PDF-1.4 conteúdo do arquivo")
+
+	if err := s3.Put(ctx, "blobs/1/ab/abc.pdf", bytes.NewReader(data), int64(len(data)), "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if got := readObject(t, s3, "blobs/1/ab/abc.pdf"); !bytes.Equal(got, data) {
+		t.Errorf("Get = %q", got)
+	}
+
+	info, err := s3.Stat(ctx, "blobs/1/ab/abc.pdf")
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if info.Key != "blobs/1/ab/abc.pdf" || info.Size != int64(len(data)) || info.ContentType != "application/pdf" || info.ModTime.IsZero() {
+		t.Errorf("Stat = %+v", info)
+	}
+}
+
+func TestS3PutUnknownSize(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+
+	// Maior que uma parte, para passar pelo multipart
+	data := make([]byte, streamPartSize+1234)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("gerar dados: %v", err)
+	}
+
+	size, checksum, err := PutHashed(ctx, s3, "incoming/1/upload", bytes.NewReader(data), "video/mp4")
+	if err != nil {
+		t.Fatalf("PutHashed: %v", err)
+	}
+	if size != int64(len(data)) || len(checksum) != 64 {
+		t.Errorf("PutHashed = %d, %q", size, checksum)
+	}
+	if got := readObject(t, s3, "incoming/1/upload"); !bytes.Equal(got, data) {
+		t.Errorf("conteúdo gravado difere (%d bytes, esperado %d)", len(got), len(data))
+	}
+
+	small := []byte("pequeno")
+	if err := s3.Put(ctx, "incoming/1/small", bytes.NewReader(small), -1, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if got := readObject(t, s3, "incoming/1/small"); !bytes.Equal(got, small) {
+		t.Errorf("Get = %q", got)
+	}
+}
+
+func TestS3Move(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+	data := []byte("conteúdo movido")
+
+	if err := s3.Put(ctx, "incoming/1/x", bytes.NewReader(data), int64(len(data)), "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s3.Move(ctx, "incoming/1/x", "blobs/1/xx/x.pdf"); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+
+	if got := readObject(t, s3, "blobs/1/xx/x.pdf"); !bytes.Equal(got, data) {
+		t.Errorf("destino = %q", got)
+	}
+	if _, err := s3.Stat(ctx, "incoming/1/x"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("origem após Move: %v", err)
+	}
+}
+
+func TestS3Delete(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+
+	if err := s3.Put(ctx, "cache/1/x.pdf", strings.NewReader("x"), 1, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := s3.Delete(ctx, "cache/1/x.pdf"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := s3.Stat(ctx, "cache/1/x.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat após Delete: %v", err)
+	}
+
+	// Como no backend local, remover uma chave inexistente não é erro
+	if err := s3.Delete(ctx, "cache/1/x.pdf"); err != nil {
+		t.Errorf("Delete de chave inexistente: %v", err)
+	}
+}
+
+func TestS3NotFound(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+
+	if _, err := s3.Get(ctx, "nao/existe"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get: %v", err)
+	}
+	if _, err := s3.Stat(ctx, "nao/existe"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat: %v", err)
+	}
+	if err := s3.Move(ctx, "nao/existe", "outro"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Move: %v", err)
+	}
+	if _, _, err := FetchToFile(ctx, s3, "nao/existe"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FetchToFile: %v", err)
+	}
+}
+
+func TestS3OtherErrorsAreNotNotFound(t *testing.T) {
+	s3, server := newTestS3(t)
+	server.Close()
+
+	// O prazo curto evita esperar todas as novas tentativas do cliente
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if _, err := s3.Stat(ctx, "qualquer"); err == nil || errors.Is(err, ErrNotFound) {
+		t.Errorf("Stat com servidor fora do ar: %v", err)
+	}
+}
+
+func TestS3PresignGet(t *testing.T) {
+	s3, _ := newTestS3(t)
+	ctx := context.Background()
+
+	if err := s3.Put(ctx, "cache/1/doc.pdf", strings.NewReader("pdf"), 3, "application/pdf"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	presigned, err := s3.PresignGet(ctx, "cache/1/doc.pdf", time.Minute, "relatório.pdf")
+	if err != nil {
+		t.Fatalf("PresignGet: %v", err)
+	}
+	parsed, err := url.Parse(presigned)
+	if err != nil {
+		t.Fatalf("URL inválida %q: %v", presigned, err)
+	}
+	if parsed.Path != "/drm-test/cache/1/doc.pdf" || parsed.Query().Get("X-Amz-Signature") == "" {
+		t.Errorf("URL = %s", presigned)
+	}
+
+	resp, err := http.Get(presigned)
+	if err != nil {
+		t.Fatalf("GET %s: %v", presigned, err)
+	}
+	defer resp.Body.Close()
+	if want := fmt.Sprintf("attachment; filename=%q", "relatório.pdf"); resp.Header.Get("Content-Disposition") != want {
+		t.Errorf("Content-Disposition = %q, esperado %q", resp.Header.Get("Content-Disposition"), want)
+	}
+}
+
+func TestNewS3CreatesBucket(t *testing.T) {
+	server := newFakeS3(t)
+	endpoint := strings.TrimPrefix(server.URL, "http://")
+
+	for i := 0; i < 2; i++ {
+		// Na segunda vez o bucket já existe
+		if _, err := NewS3(endpoint, "novo-bucket", "us-east-1", "access", "secret", false); err != nil {
+			t.Fatalf("NewS3 (%d): %v", i+1, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNotFound é retornado quando a chave não existe no backend
+var ErrNotFound = errors.New("objeto não encontrado")
+
+// ErrPresignUnsupported é retornado por backends que não geram URLs assinadas
+var ErrPresignUnsupported = errors.New("backend não suporta URLs assinadas")
+
+// ObjectInfo descreve um objeto armazenado
+type ObjectInfo struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage abstrai onde os arquivos (originais e cópias com marca d'água)
+// ficam guardados. As chaves são caminhos relativos com "/" como separador,
+// por exemplo "temp/1/123_arquivo.pdf".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// Move renomeia src para dst sem transferir o conteúdo pela aplicação
+	Move(ctx context.Context, src, dst string) error
+	PresignGet(ctx context.Context, key string, expiry time.Duration, filename string) (string, error)
+}
+
+// Default é o backend configurado por STORAGE_TYPE
+var Default Storage
+
+// Init configura o backend padrão a partir de STORAGE_TYPE ("local" ou "s3")
+func Init() error {
+	storageType := os.Getenv("STORAGE_TYPE")
+	log.Println("Tipo de armazenamento:", storageType)
+
+	switch storageType {
+	case "", "local":
+		root := os.Getenv("LOCAL_STORAGE_ROOT")
+		if root == "" {
+			root = "."
+		}
+		Default = NewLocal(root)
+	case "s3":
+		s3, err := NewS3FromEnv()
+		if err != nil {
+			return err
+		}
+		Default = s3
+	default:
+		return fmt.Errorf("tipo de armazenamento não suportado: %s", storageType)
+	}
+
+	return nil
+}
+
+// FetchToFile disponibiliza o objeto como arquivo local, necessário para
+// ferramentas como ffmpeg e pdfcpu. No backend local o próprio arquivo é
+// usado; nos demais é feita uma cópia temporária. cleanup deve ser sempre
+// chamado ao final.
+func FetchToFile(ctx context.Context, s Storage, key string) (path string, cleanup func(), err error) {
+	if local, ok := s.(*Local); ok {
+		path, err := local.path(key)
+		if err != nil {
+			return "", func() {}, err
+		}
+		if _, err := os.Stat(path); err != nil {
+			if os.IsNotExist(err) {
+				return "", func() {}, ErrNotFound
+			}
+			return "", func() {}, err
+		}
+		return path, func() {}, nil
+	}
+
+	r, err := s.Get(ctx, key)
+	if err != nil {
+		return "", func() {}, err
+	}
+	defer r.Close()
+
+	tmp, err := os.CreateTemp("", "fetch-*"+filepath.Ext(key))
+	if err != nil {
+		return "", func() {}, err
+	}
+	cleanup = func() { os.Remove(tmp.Name()) }
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		cleanup()
+		return "", func() {}, err
+	}
+	if err := tmp.Close(); err != nil {
+		cleanup()
+		return "", func() {}, err
+	}
+
+	return tmp.Name(), cleanup, nil
+}
+
+// PutHashed grava r em key numa única passagem, calculando o tamanho e o
+// SHA-256 (em hexadecimal) do que foi gravado
+func PutHashed(ctx context.Context, s Storage, key string, r io.Reader, contentType string) (int64, string, error) {
+	hash := sha256.New()
+	counter := &countingWriter{}
+	tee := io.TeeReader(r, io.MultiWriter(hash, counter))
+
+	if err := s.Put(ctx, key, tee, -1, contentType); err != nil {
+		return 0, "", err
+	}
+
+	return counter.n, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
+
+// PutFile envia um arquivo local para o backend e remove o arquivo de origem
+func PutFile(ctx context.Context, s Storage, key, localPath, contentType string) error {
+	if local, ok := s.(*Local); ok {
+		return local.moveFile(localPath, key)
+	}
+
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := s.Put(ctx, key, f, info.Size(), contentType); err != nil {
+		return err
+	}
+
+	f.Close()
+	return os.Remove(localPath)
+}
@@ -0,0 +1,147 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"projeto_drm/poc/internal/models"
+
+	"github.com/pdfcpu/pdfcpu/pkg/api"
+)
+
+func init() {
+	Register(func(models.ValidationRules) Check { return typeCheck{} })
+	Register(func(models.ValidationRules) Check { return pdfCheck{} })
+	Register(func(models.ValidationRules) Check { return videoCheck{} })
+	Register(func(rules models.ValidationRules) Check {
+		if len(rules.MaxSize) == 0 {
+			return nil
+		}
+		return sizeLimitCheck{limits: rules.MaxSize}
+	})
+	Register(func(rules models.ValidationRules) Check {
+		if rules.MaxPages <= 0 {
+			return nil
+		}
+		return pageLimitCheck{max: rules.MaxPages}
+	})
+	Register(func(rules models.ValidationRules) Check {
+		if rules.MaxDurationSeconds <= 0 {
+			return nil
+		}
+		return durationLimitCheck{max: rules.MaxDurationSeconds}
+	})
+}
+
+// typeCheck identifica o tipo pelo conteúdo e o confere com o tipo declarado
+// e a extensão
+type typeCheck struct{}
+
+func (typeCheck) Name() string { return "type" }
+
+func (typeCheck) Validate(ctx context.Context, f *File) error {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	head := make([]byte, SniffLength)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	f.DetectedType = Sniff(head[:n])
+	return CheckType(f.Name, f.DeclaredType, f.DetectedType)
+}
+
+// pdfCheck valida a estrutura do PDF com o pdfcpu e conta as páginas
+type pdfCheck struct{}
+
+func (pdfCheck) Name() string { return "pdf" }
+
+func (pdfCheck) Validate(ctx context.Context, f *File) error {
+	if f.DetectedType != "application/pdf" {
+		return nil
+	}
+
+	if err := api.ValidateFile(f.Path, nil); err != nil {
+		return Reject("pdf", "PDF inválido: %v", err)
+	}
+
+	pages, err := api.PageCountFile(f.Path)
+	if err != nil {
+		return Reject("pdf", "não foi possível contar as páginas: %v", err)
+	}
+	f.Pages = pages
+	return nil
+}
+
+// videoCheck confirma com o ffprobe que o arquivo é um vídeo legível
+type videoCheck struct{}
+
+func (videoCheck) Name() string { return "video" }
+
+func (videoCheck) Validate(ctx context.Context, f *File) error {
+	if f.DetectedType != "video/mp4" && f.DetectedType != "video/quicktime" {
+		return nil
+	}
+
+	probe, err := ProbeVideo(ctx, f.Path)
+	if errors.Is(err, ErrInvalidMedia) {
+		return Reject("video", "vídeo ilegível ou corrompido")
+	}
+	if err != nil {
+		return err
+	}
+	if !probe.HasVideo() {
+		return Reject("video", "o arquivo não contém trilha de vídeo")
+	}
+
+	f.Duration = probe.Duration()
+	return nil
+}
+
+// sizeLimitCheck aplica o tamanho máximo por tipo definido pelo tenant
+type sizeLimitCheck struct {
+	limits map[string]int64
+}
+
+func (sizeLimitCheck) Name() string { return "max_size" }
+
+func (c sizeLimitCheck) Validate(ctx context.Context, f *File) error {
+	if limit, ok := c.limits[f.DetectedType]; ok && limit > 0 && f.Size > limit {
+		return Reject("max_size", "%d bytes excedem o limite de %d para %s", f.Size, limit, f.DetectedType)
+	}
+	return nil
+}
+
+// pageLimitCheck aplica o número máximo de páginas de PDFs
+type pageLimitCheck struct {
+	max int
+}
+
+func (pageLimitCheck) Name() string { return "max_pages" }
+
+func (c pageLimitCheck) Validate(ctx context.Context, f *File) error {
+	if f.Pages > c.max {
+		return Reject("max_pages", "%d páginas excedem o limite de %d", f.Pages, c.max)
+	}
+	return nil
+}
+
+// durationLimitCheck aplica a duração máxima de vídeos
+type durationLimitCheck struct {
+	max float64
+}
+
+func (durationLimitCheck) Name() string { return "max_duration" }
+
+func (c durationLimitCheck) Validate(ctx context.Context, f *File) error {
+	if f.Duration > c.max {
+		return Reject("max_duration", "%.0f segundos excedem o limite de %.0f", f.Duration, c.max)
+	}
+	return nil
+}
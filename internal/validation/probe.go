@@ -0,0 +1,81 @@
+package validation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os/exec"
+	"strconv"
+)
+
+// ErrInvalidMedia é retornado quando o ffprobe não consegue ler o arquivo
+var ErrInvalidMedia = errors.New("arquivo de mídia inválido")
+
+// Probe é a saída do ffprobe com os campos usados pelas checagens e pela
+// extração de metadados técnicos
+type Probe struct {
+	Streams []ProbeStream `json:"streams"`
+	Format  struct {
+		Duration string `json:"duration"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// ProbeStream é uma trilha do arquivo
+type ProbeStream struct {
+	CodecType string `json:"codec_type"`
+	CodecName string `json:"codec_name"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Channels  int    `json:"channels"`
+	Tags      struct {
+		Language string `json:"language"`
+	} `json:"tags"`
+}
+
+// HasVideo indica se há ao menos uma trilha de vídeo
+func (p Probe) HasVideo() bool {
+	for _, stream := range p.Streams {
+		if stream.CodecType == "video" {
+			return true
+		}
+	}
+	return false
+}
+
+// Duration retorna a duração em segundos, ou 0 se desconhecida
+func (p Probe) Duration() float64 {
+	duration, _ := strconv.ParseFloat(p.Format.Duration, 64)
+	return duration
+}
+
+// BitRate retorna a taxa de bits total em bits/s, ou 0 se desconhecida
+func (p Probe) BitRate() int64 {
+	bitRate, _ := strconv.ParseInt(p.Format.BitRate, 10, 64)
+	return bitRate
+}
+
+// ProbeVideo executa o ffprobe no arquivo
+func ProbeVideo(ctx context.Context, path string) (Probe, error) {
+	var probe Probe
+
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration,bit_rate:stream=codec_type,codec_name,width,height,channels:stream_tags=language",
+		"-of", "json",
+		path,
+	)
+	output, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return probe, ErrInvalidMedia
+		}
+		return probe, err
+	}
+
+	if err := json.Unmarshal(output, &probe); err != nil {
+		return probe, ErrInvalidMedia
+	}
+	return probe, nil
+}
@@ -0,0 +1,63 @@
+package validation
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// SniffLength é quanto do início do arquivo basta para identificar o tipo
+const SniffLength = 3072
+
+// supportedTypes relaciona os tipos aceitos às extensões permitidas; a
+// primeira é a extensão canônica
+var supportedTypes = map[string][]string{
+	"application/pdf": {".pdf"},
+	"video/mp4":       {".mp4"},
+	"video/quicktime": {".mov"},
+}
+
+// Supported indica se o Content-Type é aceito no upload
+func Supported(contentType string) bool {
+	_, ok := supportedTypes[contentType]
+	return ok
+}
+
+// Extension retorna a extensão canônica do tipo
+func Extension(contentType string) string {
+	if exts := supportedTypes[contentType]; len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// Sniff identifica o tipo real a partir dos primeiros bytes do arquivo,
+// retornando "" quando não é um dos tipos aceitos
+func Sniff(head []byte) string {
+	for m := mimetype.Detect(head); m != nil; m = m.Parent() {
+		if Supported(m.String()) {
+			return m.String()
+		}
+	}
+	return ""
+}
+
+// CheckType confere o tipo detectado com o tipo declarado pelo cliente e com
+// a extensão do nome do arquivo
+func CheckType(name, declared, detected string) error {
+	if detected == "" {
+		return Reject("type", "conteúdo não corresponde a nenhum tipo suportado")
+	}
+	if declared != "" && declared != "application/octet-stream" && declared != detected {
+		return Reject("type", "tipo declarado %s, mas o conteúdo é %s", declared, detected)
+	}
+
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, allowed := range supportedTypes[detected] {
+		if ext == allowed {
+			return nil
+		}
+	}
+	return Reject("type", "extensão %q não corresponde ao conteúdo %s", ext, detected)
+}
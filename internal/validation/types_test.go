@@ -0,0 +1,242 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"projeto_drm/poc/internal/models"
+	"reflect"
+	"testing"
+)
+
+// minimalPDF é um PDF válido de uma página
+const minimalPDF = "%PDF-1.4\n" +
+	"1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n" +
+	"2 0 obj\n<< /Type /Pages /Kids [3 0 R] /Count 1 >>\nendobj\n" +
+	"3 0 obj\n<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << >> >>\nendobj\n" +
+	"xref\n0 4\n0000000000 65535 f \n0000000009 00000 n \n0000000058 00000 n \n0000000115 00000 n \n" +
+	"trailer\n<< /Size 4 /Root 1 0 R >>\nstartxref\n203\n%%EOF\n"
+
+var (
+	mp4Head = []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2\x00\x00\x00\x08free")
+	movHead = []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x00\x00qt  \x00\x00\x00\x08wide")
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"pdf", []byte(minimalPDF), "application/pdf"},
+		{"mp4", mp4Head, "video/mp4"},
+		{"quicktime", movHead, "video/quicktime"},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), ""},
+		{"zip", []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"), ""},
+		{"texto", []byte("apenas texto, não um PDF"), ""},
+		{"html", []byte("<!DOCTYPE html><html><body>%PDF-1.4</body></html>"), ""},
+		{"vazio", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sniff(tt.head); got != tt.want {
+				t.Errorf("Sniff = %q, esperado %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckType(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		declared string
+		detected string
+		ok       bool
+	}{
+		{"pdf declarado", "contrato.pdf", "application/pdf", "application/pdf", true},
+		{"octet-stream", "contrato.pdf", "application/octet-stream", "application/pdf", true},
+		{"sem tipo declarado", "contrato.pdf", "", "application/pdf", true},
+		{"extensão em maiúsculas", "CONTRATO.PDF", "application/pdf", "application/pdf", true},
+		{"mp4 como octet-stream", "aula.mp4", "application/octet-stream", "video/mp4", true},
+		{"quicktime", "aula.mov", "video/quicktime", "video/quicktime", true},
+
+		{"tipo não suportado", "foto.png", "image/png", "", false},
+		{"octet-stream não suportado", "foto.pdf", "application/octet-stream", "", false},
+		{"declarado pdf, conteúdo mp4", "aula.pdf", "application/pdf", "video/mp4", false},
+		{"declarado mp4, conteúdo pdf", "contrato.mp4", "video/mp4", "application/pdf", false},
+		{"declarado outro tipo", "contrato.pdf", "text/plain", "application/pdf", false},
+		{"extensão de outro tipo", "contrato.mp4", "application/pdf", "application/pdf", false},
+		{"extensão de outro vídeo", "aula.mov", "application/octet-stream", "video/mp4", false},
+		{"sem extensão", "contrato", "application/pdf", "application/pdf", false},
+		{"extensão dupla", "contrato.pdf.exe", "application/octet-stream", "application/pdf", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckType(tt.file, tt.declared, tt.detected)
+			if tt.ok {
+				if err != nil {
+					t.Fatalf("CheckType = %v", err)
+				}
+				return
+			}
+
+			var rejected *RejectedError
+			if !errors.As(err, &rejected) || rejected.Check != "type" {
+				t.Fatalf("CheckType = %v, esperado RejectedError da checagem type", err)
+			}
+		})
+	}
+}
+
+func checkNames(p Pipeline) []string {
+	names := []string{}
+	for _, check := range p {
+		names = append(names, check.Name())
+	}
+	return names
+}
+
+func TestForTenant(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules models.ValidationRules
+		want  []string
+	}{
+		{"sem regras", models.ValidationRules{}, []string{"type", "pdf", "video"}},
+		{"tamanho", models.ValidationRules{MaxSize: map[string]int64{"application/pdf": 1024}}, []string{"type", "pdf", "video", "max_size"}},
+		{"páginas", models.ValidationRules{MaxPages: 10}, []string{"type", "pdf", "video", "max_pages"}},
+		{"duração", models.ValidationRules{MaxDurationSeconds: 60}, []string{"type", "pdf", "video", "max_duration"}},
+		{"limites não positivos", models.ValidationRules{MaxPages: -1, MaxDurationSeconds: 0}, []string{"type", "pdf", "video"}},
+		{"todas", models.ValidationRules{
+			MaxSize:            map[string]int64{"video/mp4": 1 << 20},
+			MaxPages:           10,
+			MaxDurationSeconds: 60,
+		}, []string{"type", "pdf", "video", "max_size", "max_pages", "max_duration"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkNames(ForTenant(tt.rules)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checagens = %v, esperado %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeCheck registra a chamada e devolve err
+type fakeCheck struct {
+	name  string
+	err   error
+	calls *[]string
+}
+
+func (c fakeCheck) Name() string { return c.name }
+
+func (c fakeCheck) Validate(ctx context.Context, f *File) error {
+	*c.calls = append(*c.calls, c.name)
+	return c.err
+}
+
+func TestPipelineRunStopsAtFirstFailure(t *testing.T) {
+	var calls []string
+	rejected := Reject("b", "recusado")
+	pipeline := Pipeline{
+		fakeCheck{name: "a", calls: &calls},
+		fakeCheck{name: "b", err: rejected, calls: &calls},
+		fakeCheck{name: "c", calls: &calls},
+	}
+
+	if err := pipeline.Run(context.Background(), &File{}); err != rejected {
+		t.Fatalf("Run = %v", err)
+	}
+	if !reflect.DeepEqual(calls, []string{"a", "b"}) {
+		t.Errorf("checagens executadas = %v", calls)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("gravar arquivo: %v", err)
+	}
+	return path
+}
+
+func TestPipelineRun(t *testing.T) {
+	pdf := writeFile(t, "upload", minimalPDF)
+
+	tests := []struct {
+		name     string
+		path     string
+		file     string
+		declared string
+		rules    models.ValidationRules
+		rejectBy string
+	}{
+		{"pdf aceito", pdf, "contrato.pdf", "application/octet-stream", models.ValidationRules{MaxPages: 1}, ""},
+		{"tipo declarado divergente", pdf, "contrato.pdf", "video/mp4", models.ValidationRules{}, "type"},
+		{"extensão divergente", pdf, "contrato.mov", "application/pdf", models.ValidationRules{}, "type"},
+		{"conteúdo não suportado", writeFile(t, "upload", "apenas texto"), "notas.pdf", "application/pdf", models.ValidationRules{}, "type"},
+		{"pdf corrompido", writeFile(t, "upload", "%PDF-1.4\nlixo"), "contrato.pdf", "application/pdf", models.ValidationRules{}, "pdf"},
+		{"tamanho", pdf, "contrato.pdf", "application/pdf", models.ValidationRules{MaxSize: map[string]int64{"application/pdf": 100}}, "max_size"},
+		{"tamanho de outro tipo", pdf, "contrato.pdf", "application/pdf", models.ValidationRules{MaxSize: map[string]int64{"video/mp4": 100}}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := os.Stat(tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := &File{Path: tt.path, Name: tt.file, DeclaredType: tt.declared, Size: info.Size()}
+
+			err = ForTenant(tt.rules).Run(context.Background(), f)
+			if tt.rejectBy == "" {
+				if err != nil {
+					t.Fatalf("Run = %v", err)
+				}
+				if f.DetectedType != "application/pdf" || f.Pages != 1 {
+					t.Errorf("arquivo = tipo %q, %d páginas", f.DetectedType, f.Pages)
+				}
+				return
+			}
+
+			var rejected *RejectedError
+			if !errors.As(err, &rejected) || rejected.Check != tt.rejectBy {
+				t.Fatalf("Run = %v, esperado recusa por %s", err, tt.rejectBy)
+			}
+		})
+	}
+}
+
+func TestLimitChecks(t *testing.T) {
+	tests := []struct {
+		name  string
+		check Check
+		file  File
+		ok    bool
+	}{
+		{"páginas no limite", pageLimitCheck{max: 10}, File{Pages: 10}, true},
+		{"páginas acima", pageLimitCheck{max: 10}, File{Pages: 11}, false},
+		{"duração no limite", durationLimitCheck{max: 60}, File{Duration: 60}, true},
+		{"duração acima", durationLimitCheck{max: 60}, File{Duration: 60.5}, false},
+		{"tamanho acima", sizeLimitCheck{limits: map[string]int64{"video/mp4": 100}}, File{DetectedType: "video/mp4", Size: 101}, false},
+		{"limite zero ignorado", sizeLimitCheck{limits: map[string]int64{"video/mp4": 0}}, File{DetectedType: "video/mp4", Size: 101}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.check.Validate(context.Background(), &tt.file)
+			var rejected *RejectedError
+			if tt.ok != (err == nil) || (err != nil && (!errors.As(err, &rejected) || rejected.Check != tt.check.Name())) {
+				t.Errorf("Validate = %v", err)
+			}
+		})
+	}
+}
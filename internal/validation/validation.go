@@ -0,0 +1,79 @@
+package validation
+
+import (
+	"context"
+	"fmt"
+	"projeto_drm/poc/internal/models"
+)
+
+// File é o arquivo enviado, já disponível em disco, passando pelas checagens.
+// Checagens anteriores preenchem DetectedType, Pages e Duration para que as
+// seguintes não precisem reabrir o arquivo.
+type File struct {
+	Path         string
+	Name         string
+	DeclaredType string
+	Size         int64
+
+	DetectedType string
+	Pages        int
+	Duration     float64 // segundos
+}
+
+// Check é uma etapa da validação. Conteúdo inaceitável deve ser reportado
+// com Reject; outros erros indicam falha da própria checagem.
+type Check interface {
+	Name() string
+	Validate(ctx context.Context, f *File) error
+}
+
+// RejectedError indica que o arquivo foi recusado por uma checagem
+type RejectedError struct {
+	Check  string
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Check, e.Reason)
+}
+
+// Reject cria um RejectedError com a razão formatada
+func Reject(check, format string, args ...any) error {
+	return &RejectedError{Check: check, Reason: fmt.Sprintf(format, args...)}
+}
+
+// CheckBuilder cria uma checagem a partir das regras do tenant, ou retorna
+// nil quando a checagem não se aplica a essas regras
+type CheckBuilder func(rules models.ValidationRules) Check
+
+var builders []CheckBuilder
+
+// Register adiciona uma checagem ao pipeline de todos os tenants. As
+// checagens rodam na ordem de registro.
+func Register(builder CheckBuilder) {
+	builders = append(builders, builder)
+}
+
+// Pipeline é a sequência de checagens aplicada aos uploads de um tenant
+type Pipeline []Check
+
+// ForTenant monta o pipeline com as regras do tenant
+func ForTenant(rules models.ValidationRules) Pipeline {
+	var pipeline Pipeline
+	for _, build := range builders {
+		if check := build(rules); check != nil {
+			pipeline = append(pipeline, check)
+		}
+	}
+	return pipeline
+}
+
+// Run executa as checagens em ordem e para na primeira falha
+func (p Pipeline) Run(ctx context.Context, f *File) error {
+	for _, check := range p {
+		if err := check.Validate(ctx, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
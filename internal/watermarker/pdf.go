@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"github.com/pdfcpu/pdfcpu/pkg/api"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu"
+	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/model"
 	"github.com/pdfcpu/pdfcpu/pkg/pdfcpu/types"
+	"os"
 )
 
-func AddPDFWatermark(inputPath, outputPath, userID string) error {
+// pdfProgressSteps é em quantos lotes de páginas a marca d'água é aplicada,
+// reportando o progresso ao fim de cada um
+const pdfProgressSteps = 10
+
+func AddPDFWatermark(inputPath, outputPath, userID string, progress ProgressFunc) error {
 	description := fmt.Sprintf(`Licensed to: %s`, userID)
 
 	wm, err := pdfcpu.ParseTextWatermarkDetails(description, "", false, types.POINTS)
@@ -15,9 +21,38 @@ func AddPDFWatermark(inputPath, outputPath, userID string) error {
 		return fmt.Errorf("erro ao criar configuração de marca d'água: %v", err)
 	}
 
-	if err := api.AddWatermarksFile(inputPath, outputPath, nil, wm, nil); err != nil {
-		return fmt.Errorf("erro ao adicionar marca d'água: %v", err)
+	conf := model.NewDefaultConfiguration()
+	conf.Cmd = model.ADDWATERMARKS
+	conf.OptimizeDuplicateContentStreams = false
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		return fmt.Errorf("erro ao abrir PDF: %v", err)
+	}
+	ctx, err := api.ReadValidateAndOptimize(input, conf)
+	input.Close()
+	if err != nil {
+		return fmt.Errorf("erro ao ler PDF: %v", err)
+	}
+
+	// A gravação fica com a última fatia do progresso
+	batch := (ctx.PageCount + pdfProgressSteps - 1) / pdfProgressSteps
+	for first := 1; first <= ctx.PageCount; first += batch {
+		last := min(first+batch-1, ctx.PageCount)
+		pages := types.IntSet{}
+		for page := first; page <= last; page++ {
+			pages[page] = true
+		}
+		if err := pdfcpu.AddWatermarks(ctx, pages, wm); err != nil {
+			return fmt.Errorf("erro ao adicionar marca d'água: %v", err)
+		}
+		progress.report(float64(last) / float64(ctx.PageCount) * 90)
+	}
+
+	if err := api.WriteContextFile(ctx, outputPath); err != nil {
+		return fmt.Errorf("erro ao gravar PDF com marca d'água: %v", err)
 	}
+	progress.report(100)
 
 	return nil
 }
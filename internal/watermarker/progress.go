@@ -0,0 +1,71 @@
+package watermarker
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os/exec"
+	"projeto_drm/poc/internal/validation"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProgressFunc recebe o percentual concluído (0 a 100) da marca d'água. Pode
+// ser nil.
+type ProgressFunc func(percent float64)
+
+func (p ProgressFunc) report(percent float64) {
+	if p == nil {
+		return
+	}
+	if percent > 100 {
+		percent = 100
+	}
+	p(percent)
+}
+
+// videoDuration obtém a duração em segundos para calcular o progresso do
+// ffmpeg; retorna 0 se o ffprobe falhar, e então só o fim é reportado
+func videoDuration(path string) float64 {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	probe, err := validation.ProbeVideo(ctx, path)
+	if err != nil {
+		return 0
+	}
+	return probe.Duration()
+}
+
+// runWithProgress executa o ffmpeg com -progress na saída padrão e converte
+// o tempo já codificado (out_time_us) em percentual da duração
+func runWithProgress(cmd *exec.Cmd, duration float64, progress ProgressFunc) error {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	readProgress(stdout, duration, progress)
+	return cmd.Wait()
+}
+
+func readProgress(r io.Reader, duration float64, progress ProgressFunc) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), "=")
+		if !ok || key != "out_time_us" || duration <= 0 {
+			continue
+		}
+		micros, err := strconv.ParseFloat(value, 64)
+		if err != nil || micros < 0 {
+			continue
+		}
+		progress.report(micros / 1e6 / duration * 100)
+	}
+	// Drena o restante para o ffmpeg não bloquear na escrita
+	io.Copy(io.Discard, r)
+}
@@ -16,8 +16,9 @@ func getCPUCount() int {
 	return 1
 }
 
-func AddVideoWatermark(inputPath, outputPath, userID string) error {
+func AddVideoWatermark(inputPath, outputPath, userID string, progress ProgressFunc) error {
 	cpuCount := getCPUCount()
+	duration := videoDuration(inputPath)
 
 	cmd := exec.Command("ffmpeg",
 		// Hardware acceleration (se disponível)
@@ -48,18 +49,21 @@ func AddVideoWatermark(inputPath, outputPath, userID string) error {
 		"-movflags", "+faststart", // Otimiza para streaming
 		"-avoid_negative_ts", "make_zero",
 
+		// Progresso na saída padrão, lido por runWithProgress
+		"-progress", "pipe:1",
+		"-nostats",
+
 		// Output
 		"-y", // Sobrescrever arquivo se existir
 		outputPath,
 	)
 
 	cmd.Stderr = os.Stderr
-	cmd.Stdout = os.Stdout
 
 	fmt.Printf("Processando vídeo com %d threads...\n", cpuCount)
 	fmt.Println("Executando comando ffmpeg:", cmd.String())
 
-	if err := cmd.Run(); err != nil {
+	if err := runWithProgress(cmd, duration, progress); err != nil {
 		return fmt.Errorf("erro ao adicionar watermark no vídeo: %v", err)
 	}
 
@@ -73,8 +77,9 @@ func AddVideoWatermark(inputPath, outputPath, userID string) error {
 	return nil
 }
 
-func AddVideoWatermarkLarge(inputPath, outputPath, userID string) error {
+func AddVideoWatermarkLarge(inputPath, outputPath, userID string, progress ProgressFunc) error {
 	cpuCount := getCPUCount()
+	duration := videoDuration(inputPath)
 
 	cmd := exec.Command("ffmpeg",
 		// Hardware acceleration
@@ -103,6 +108,9 @@ func AddVideoWatermarkLarge(inputPath, outputPath, userID string) error {
 		"-movflags", "+faststart",
 		"-fflags", "+genpts",
 
+		"-progress", "pipe:1",
+		"-nostats",
+
 		"-y",
 		outputPath,
 	)
@@ -111,7 +119,7 @@ func AddVideoWatermarkLarge(inputPath, outputPath, userID string) error {
 
 	fmt.Printf("Processando arquivo grande com configurações ultra-rápidas...\n")
 
-	if err := cmd.Run(); err != nil {
+	if err := runWithProgress(cmd, duration, progress); err != nil {
 		return fmt.Errorf("erro ao processar arquivo grande: %v", err)
 	}
 
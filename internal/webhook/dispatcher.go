@@ -0,0 +1,293 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxAttempts = 8
+	defaultRetryDelay  = 30 * time.Second
+	defaultTimeout     = 10 * time.Second
+	maxRetryDelay      = time.Hour
+
+	dispatchBatch       = 50
+	dispatchConcurrency = 4
+	// maxResponseBody limita o corpo da resposta guardado no log de entregas
+	maxResponseBody = 1024
+)
+
+var (
+	maxAttempts = defaultMaxAttempts
+	retryDelay  = defaultRetryDelay
+	client      = newClient(defaultTimeout)
+
+	// allowPrivateTargets libera destinos em redes internas
+	// (WEBHOOK_ALLOW_PRIVATE_TARGETS), apenas para desenvolvimento
+	allowPrivateTargets = false
+
+	// wake antecipa o próximo ciclo do dispatcher quando há entregas novas
+	wake = make(chan struct{}, 1)
+)
+
+func wakeDispatcher() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// StartDispatcher envia as entregas pendentes a cada interval ou assim que
+// um evento é emitido. Lê WEBHOOK_MAX_ATTEMPTS, WEBHOOK_RETRY_BASE_DELAY,
+// WEBHOOK_TIMEOUT e WEBHOOK_ALLOW_PRIVATE_TARGETS.
+func StartDispatcher(interval time.Duration) {
+	loadConfig()
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		for {
+			dispatchDue()
+			select {
+			case <-ticker.C:
+			case <-wake:
+			}
+		}
+	}()
+}
+
+func loadConfig() {
+	if value := os.Getenv("WEBHOOK_MAX_ATTEMPTS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			maxAttempts = parsed
+		} else {
+			log.Printf("WEBHOOK_MAX_ATTEMPTS inválido (%q), usando %d", value, maxAttempts)
+		}
+	}
+	if value := os.Getenv("WEBHOOK_RETRY_BASE_DELAY"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			retryDelay = parsed
+		} else {
+			log.Printf("WEBHOOK_RETRY_BASE_DELAY inválido (%q), usando %s", value, retryDelay)
+		}
+	}
+	if value := os.Getenv("WEBHOOK_TIMEOUT"); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil && parsed > 0 {
+			client = newClient(parsed)
+		} else {
+			log.Printf("WEBHOOK_TIMEOUT inválido (%q), usando %s", value, client.Timeout)
+		}
+	}
+	allowPrivateTargets = os.Getenv("WEBHOOK_ALLOW_PRIVATE_TARGETS") == "true"
+}
+
+var errPrivateTarget = errors.New("destino do webhook em rede interna não permitido")
+
+// newClient cria o cliente das entregas. O endereço é conferido na conexão,
+// já resolvido, para que um nome que aponte (ou passe a apontar) para a rede
+// interna não seja alcançado; redirecionamentos não são seguidos e proxies do
+// ambiente não são usados, pelo mesmo motivo.
+func newClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowedIP(ip) {
+				return errPrivateTarget
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// allowedIP recusa loopback, redes privadas, link-local (incluindo o serviço
+// de metadados das nuvens), multicast e o endereço não especificado
+func allowedIP(ip net.IP) bool {
+	if allowPrivateTargets {
+		return true
+	}
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified())
+}
+
+// AllowedHost diz se o host de uma URL de webhook é aceito antes de qualquer
+// resolução de nome: IPs literais e localhost em redes internas são recusados
+// já no cadastro; nomes são conferidos novamente a cada conexão
+func AllowedHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return allowPrivateTargets
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return allowedIP(ip)
+	}
+	return true
+}
+
+// dispatchDue envia as entregas cujo horário chegou
+func dispatchDue() {
+	var due []models.WebhookDelivery
+	err := database.DB.
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+		Order("next_attempt_at").
+		Limit(dispatchBatch).
+		Find(&due).Error
+	if err != nil {
+		log.Printf("Erro ao buscar entregas de webhook pendentes: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, dispatchConcurrency)
+	for _, delivery := range due {
+		if !claim(delivery.ID) {
+			continue
+		}
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(delivery models.WebhookDelivery) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			attempt(delivery)
+		}(delivery)
+	}
+	wg.Wait()
+}
+
+// claim reserva a entrega adiando a próxima tentativa para depois do timeout
+// do envio, para que outra instância da aplicação não a envie ao mesmo tempo
+func claim(deliveryID uint) bool {
+	now := time.Now()
+	result := database.DB.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", deliveryID, models.DeliveryPending, now).
+		Update("next_attempt_at", now.Add(2*client.Timeout))
+	return result.Error == nil && result.RowsAffected == 1
+}
+
+// attempt envia a entrega e registra o resultado: entregue com resposta 2xx,
+// nova tentativa com espera exponencial ou falha ao esgotar as tentativas
+func attempt(delivery models.WebhookDelivery) {
+	delivery.Attempts++
+
+	var hook models.Webhook
+	err := database.DB.First(&hook, delivery.WebhookID).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		finish(&delivery, models.DeliveryFailed, "webhook removido")
+		return
+	case err != nil:
+		retry(&delivery, fmt.Sprintf("erro ao buscar webhook: %v", err))
+		return
+	case !hook.Active:
+		finish(&delivery, models.DeliveryFailed, "webhook desativado")
+		return
+	}
+
+	status, body, err := send(hook, delivery)
+	delivery.ResponseStatus = status
+	delivery.ResponseBody = body
+	switch {
+	case err != nil:
+		retry(&delivery, err.Error())
+	case status < 200 || status > 299:
+		retry(&delivery, fmt.Sprintf("resposta HTTP %d", status))
+	default:
+		finish(&delivery, models.DeliveryDelivered, "")
+	}
+}
+
+func send(hook models.Webhook, delivery models.WebhookDelivery) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Id", delivery.EventID)
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, timestamp, payload))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	return resp.StatusCode, string(body), nil
+}
+
+func retry(delivery *models.WebhookDelivery, cause string) {
+	if delivery.Attempts >= maxAttempts {
+		finish(delivery, models.DeliveryFailed, cause)
+		return
+	}
+
+	delay := retryDelay
+	for i := 1; i < delivery.Attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	next := time.Now().Add(delay)
+	delivery.Error = cause
+	delivery.NextAttemptAt = &next
+	save(delivery)
+}
+
+func finish(delivery *models.WebhookDelivery, status, cause string) {
+	delivery.Status = status
+	delivery.Error = cause
+	delivery.NextAttemptAt = nil
+	if status == models.DeliveryDelivered {
+		now := time.Now()
+		delivery.DeliveredAt = &now
+	} else {
+		log.Printf("Entrega %d do evento %s ao webhook %d falhou após %d tentativas: %s",
+			delivery.ID, delivery.Event, delivery.WebhookID, delivery.Attempts, cause)
+	}
+	save(delivery)
+}
+
+func save(delivery *models.WebhookDelivery) {
+	err := database.DB.Model(delivery).
+		Select("status", "attempts", "response_status", "response_body", "error", "next_attempt_at", "delivered_at").
+		Updates(delivery).Error
+	if err != nil {
+		log.Printf("Erro ao registrar entrega %d: %v", delivery.ID, err)
+	}
+}
@@ -0,0 +1,145 @@
+// Package webhook notifica endpoints externos sobre eventos de upload e
+// processamento. Os eventos viram entregas no banco, enviadas pelo
+// dispatcher com assinatura HMAC e novas tentativas com espera exponencial.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event é o corpo enviado aos webhooks
+type Event struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	TenantID  uint        `json:"tenant_id"`
+	Data      interface{} `json:"data"`
+}
+
+// Emit registra uma entrega do evento para cada webhook ativo que o assina:
+// os webhooks de tenant e os do usuário a quem o evento se refere (quem
+// enviou o asset ou pediu o download). Falhas são apenas registradas no log
+// para não afetar o upload ou o processamento.
+func Emit(eventType string, tenantID, userID uint, data interface{}) {
+	var hooks []models.Webhook
+	err := database.DB.
+		Where("tenant_id = ? AND active = ? AND (tenant_wide = ? OR user_id = ?)", tenantID, true, true, userID).
+		Find(&hooks).Error
+	if err != nil {
+		log.Printf("Erro ao buscar webhooks do evento %s: %v", eventType, err)
+		return
+	}
+
+	event := Event{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: time.Now(),
+		TenantID:  tenantID,
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Erro ao serializar evento %s: %v", eventType, err)
+		return
+	}
+
+	created := 0
+	for _, hook := range hooks {
+		if !hook.Subscribed(eventType) {
+			continue
+		}
+		now := time.Now()
+		delivery := models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			EventID:       event.ID,
+			Event:         eventType,
+			Payload:       string(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := database.DB.Create(&delivery).Error; err != nil {
+			log.Printf("Erro ao registrar entrega do evento %s ao webhook %d: %v", eventType, hook.ID, err)
+			continue
+		}
+		created++
+	}
+
+	if created > 0 {
+		wakeDispatcher()
+	}
+}
+
+// Sign calcula a assinatura enviada em X-Webhook-Signature: HMAC-SHA256 do
+// timestamp, um ponto e o corpo, com o segredo do webhook
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// GenerateSecret gera o segredo de assinatura de um webhook
+func GenerateSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// AssetData é o conteúdo dos eventos asset.*
+type AssetData struct {
+	AssetID    uint   `json:"asset_id"`
+	Name       string `json:"name"`
+	Version    int    `json:"version"`
+	Status     string `json:"status"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	SHA256     string `json:"sha256"`
+	OwnerID    uint   `json:"owner_id"`
+	ScanResult string `json:"scan_result,omitempty"`
+}
+
+// EmitAsset emite um evento asset.* para o dono do asset e os webhooks do tenant
+func EmitAsset(eventType string, asset models.Asset) {
+	version := asset.Version
+	if version == 0 {
+		version = 1
+	}
+
+	Emit(eventType, asset.TenantID, asset.OwnerID, AssetData{
+		AssetID:    asset.ID,
+		Name:       asset.Name,
+		Version:    version,
+		Status:     asset.Status,
+		Type:       asset.Type,
+		Size:       asset.Size,
+		SHA256:     asset.SHA256,
+		OwnerID:    asset.OwnerID,
+		ScanResult: asset.ScanResult,
+	})
+}
+
+// ProcessingData é o conteúdo dos eventos processing.*
+type ProcessingData struct {
+	JobID        string `json:"job_id"`
+	AssetID      string `json:"asset_id"`
+	AssetVersion int    `json:"asset_version"`
+	UserID       string `json:"user_id"`
+	Attempts     int    `json:"attempts"`
+	Error        string `json:"error,omitempty"`
+	// DownloadURL é a rota que entrega a cópia com marca d'água
+	DownloadURL string `json:"download_url,omitempty"`
+}
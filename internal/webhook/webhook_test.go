@@ -0,0 +1,416 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// receiver é um endpoint de webhook local que responde com os status da
+// fila responses (o último se repete) e guarda as requisições recebidas
+type receiver struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	responses []int
+	requests  []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, responses ...int) *receiver {
+	t.Helper()
+
+	r := &receiver{responses: responses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.requests = append(r.requests, receivedRequest{header: req.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(r.responses) > 0 {
+			status = r.responses[0]
+			if len(r.responses) > 1 {
+				r.responses = r.responses[1:]
+			}
+		}
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+		io.WriteString(w, "resposta "+strconv.Itoa(status))
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) received() []receivedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]receivedRequest(nil), r.requests...)
+}
+
+func setupTestDB(t *testing.T) {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("abrir banco: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{}); err != nil {
+		t.Fatalf("migrar banco: %v", err)
+	}
+	database.DB = db
+
+	// Restaura a configuração do dispatcher alterada pelos testes
+	attempts, delay, httpClient, allowPrivate := maxAttempts, retryDelay, client, allowPrivateTargets
+	t.Cleanup(func() {
+		maxAttempts, retryDelay, client, allowPrivateTargets = attempts, delay, httpClient, allowPrivate
+	})
+
+	// Os receptores dos testes escutam em 127.0.0.1
+	allowPrivateTargets = true
+}
+
+func createWebhook(t *testing.T, hook models.Webhook) models.Webhook {
+	t.Helper()
+
+	if hook.Secret == "" {
+		secret, err := GenerateSecret()
+		if err != nil {
+			t.Fatalf("gerar segredo: %v", err)
+		}
+		hook.Secret = secret
+	}
+	hook.Active = true
+	if err := database.DB.Create(&hook).Error; err != nil {
+		t.Fatalf("criar webhook: %v", err)
+	}
+	return hook
+}
+
+func deliveriesOf(t *testing.T, hookID uint) []models.WebhookDelivery {
+	t.Helper()
+
+	var deliveries []models.WebhookDelivery
+	if err := database.DB.Where("webhook_id = ?", hookID).Order("id").Find(&deliveries).Error; err != nil {
+		t.Fatalf("buscar entregas: %v", err)
+	}
+	return deliveries
+}
+
+// makeDue antecipa as novas tentativas para o próximo dispatchDue
+func makeDue(t *testing.T) {
+	t.Helper()
+
+	err := database.DB.Model(&models.WebhookDelivery{}).
+		Where("status = ?", models.DeliveryPending).
+		Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatalf("antecipar entregas: %v", err)
+	}
+}
+
+func TestDeliverySignature(t *testing.T) {
+	setupTestDB(t)
+	recv := newReceiver(t, http.StatusAccepted)
+	hook := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: []string{models.EventAssetUploaded}})
+
+	Emit(models.EventAssetUploaded, 1, 7, AssetData{AssetID: 42, Name: "a.pdf"})
+	dispatchDue()
+
+	requests := recv.received()
+	if len(requests) != 1 {
+		t.Fatalf("%d requisições recebidas", len(requests))
+	}
+	req := requests[0]
+
+	// O receptor confere o HMAC-SHA256 de "timestamp.corpo" com o segredo
+	timestamp := req.header.Get("X-Webhook-Timestamp")
+	if _, err := strconv.ParseInt(timestamp, 10, 64); err != nil {
+		t.Fatalf("X-Webhook-Timestamp inválido: %q", timestamp)
+	}
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write([]byte(timestamp + "." + string(req.body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	if got := req.header.Get("X-Webhook-Signature"); !hmac.Equal([]byte(got), []byte(expected)) {
+		t.Fatalf("X-Webhook-Signature = %q, esperado %q", got, expected)
+	}
+
+	var event Event
+	if err := json.Unmarshal(req.body, &event); err != nil {
+		t.Fatalf("corpo inválido: %v", err)
+	}
+	if event.Type != models.EventAssetUploaded || event.TenantID != 1 || event.ID == "" {
+		t.Errorf("evento = %+v", event)
+	}
+	if req.header.Get("X-Webhook-Id") != event.ID || req.header.Get("X-Webhook-Event") != models.EventAssetUploaded {
+		t.Errorf("headers = %v", req.header)
+	}
+
+	deliveries := deliveriesOf(t, hook.ID)
+	if len(deliveries) != 1 || deliveries[0].Status != models.DeliveryDelivered || deliveries[0].DeliveredAt == nil {
+		t.Fatalf("entregas = %+v", deliveries)
+	}
+	if deliveries[0].ResponseStatus != http.StatusAccepted || deliveries[0].ResponseBody != "resposta 202" {
+		t.Errorf("resposta registrada = %d %q", deliveries[0].ResponseStatus, deliveries[0].ResponseBody)
+	}
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	setupTestDB(t)
+	retryDelay = time.Minute
+	recv := newReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	hook := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: []string{models.EventAssetFailed}})
+
+	Emit(models.EventAssetFailed, 1, 7, AssetData{AssetID: 42})
+
+	for attempt, status := range []int{http.StatusInternalServerError, http.StatusBadGateway} {
+		before := time.Now()
+		dispatchDue()
+
+		delivery := deliveriesOf(t, hook.ID)[0]
+		if delivery.Status != models.DeliveryPending || delivery.Attempts != attempt+1 {
+			t.Fatalf("tentativa %d: entrega = %+v", attempt+1, delivery)
+		}
+		if delivery.ResponseStatus != status || delivery.ResponseBody != "resposta "+strconv.Itoa(status) {
+			t.Errorf("tentativa %d: resposta registrada = %d %q", attempt+1, delivery.ResponseStatus, delivery.ResponseBody)
+		}
+		if delivery.Error != "resposta HTTP "+strconv.Itoa(status) {
+			t.Errorf("tentativa %d: erro = %q", attempt+1, delivery.Error)
+		}
+
+		// Espera dobra a cada falha: 1m, 2m...
+		wait := retryDelay << attempt
+		if delivery.NextAttemptAt == nil || delivery.NextAttemptAt.Before(before.Add(wait)) ||
+			delivery.NextAttemptAt.After(time.Now().Add(wait)) {
+			t.Fatalf("tentativa %d: próxima tentativa em %v, esperado daqui a %s", attempt+1, delivery.NextAttemptAt, wait)
+		}
+
+		// Antes do horário a entrega não é reenviada
+		dispatchDue()
+		if got := len(recv.received()); got != attempt+1 {
+			t.Fatalf("tentativa %d: %d requisições antes do horário", attempt+1, got)
+		}
+		makeDue(t)
+	}
+
+	dispatchDue()
+	delivery := deliveriesOf(t, hook.ID)[0]
+	if delivery.Status != models.DeliveryDelivered || delivery.Attempts != 3 || delivery.NextAttemptAt != nil {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+	if delivery.ResponseStatus != http.StatusOK || delivery.Error != "" {
+		t.Errorf("resposta registrada = %d, erro %q", delivery.ResponseStatus, delivery.Error)
+	}
+}
+
+func TestDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	setupTestDB(t)
+	maxAttempts = 3
+	retryDelay = time.Minute
+	recv := newReceiver(t, http.StatusServiceUnavailable)
+	hook := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: []string{models.EventAssetFailed}})
+
+	Emit(models.EventAssetFailed, 1, 7, AssetData{AssetID: 42})
+	for i := 0; i < maxAttempts; i++ {
+		makeDue(t)
+		dispatchDue()
+	}
+
+	delivery := deliveriesOf(t, hook.ID)[0]
+	if delivery.Status != models.DeliveryFailed || delivery.Attempts != maxAttempts || delivery.NextAttemptAt != nil {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+	if delivery.ResponseStatus != http.StatusServiceUnavailable || delivery.ResponseBody != "resposta 503" ||
+		delivery.Error != "resposta HTTP 503" {
+		t.Errorf("resposta registrada = %d %q, erro %q", delivery.ResponseStatus, delivery.ResponseBody, delivery.Error)
+	}
+
+	// Entregas com falha não são mais enviadas
+	makeDue(t)
+	dispatchDue()
+	if got := len(recv.received()); got != maxAttempts {
+		t.Errorf("%d requisições, esperado %d", got, maxAttempts)
+	}
+}
+
+func TestDeliveryRecordsConnectionErrors(t *testing.T) {
+	setupTestDB(t)
+	recv := newReceiver(t)
+	url := recv.URL
+	recv.Close()
+	hook := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: url, Events: []string{models.EventAssetFailed}})
+
+	Emit(models.EventAssetFailed, 1, 7, AssetData{AssetID: 42})
+	dispatchDue()
+
+	delivery := deliveriesOf(t, hook.ID)[0]
+	if delivery.Status != models.DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != 0 || delivery.Error == "" {
+		t.Fatalf("entrega = %+v", delivery)
+	}
+}
+
+func TestEmitOnlyToSubscribedWebhooks(t *testing.T) {
+	setupTestDB(t)
+	recv := newReceiver(t)
+
+	all := []string{models.EventAssetUploaded, models.EventAssetFailed, models.EventProcessingCompleted, models.EventProcessingFailed}
+	tenantWide := createWebhook(t, models.Webhook{TenantID: 1, UserID: 1, TenantWide: true, URL: recv.URL, Events: all})
+	tenantProcessing := createWebhook(t, models.Webhook{TenantID: 1, UserID: 1, TenantWide: true, URL: recv.URL,
+		Events: []string{models.EventProcessingCompleted}})
+	owner := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: []string{models.EventAssetUploaded}})
+	otherUser := createWebhook(t, models.Webhook{TenantID: 1, UserID: 8, URL: recv.URL, Events: all})
+	otherTenant := createWebhook(t, models.Webhook{TenantID: 2, UserID: 9, TenantWide: true, URL: recv.URL, Events: all})
+	inactive := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: all})
+	database.DB.Model(&inactive).Update("active", false)
+
+	Emit(models.EventAssetUploaded, 1, 7, AssetData{AssetID: 1})
+	Emit(models.EventProcessingCompleted, 1, 7, ProcessingData{JobID: "j1"})
+	Emit(models.EventAssetFailed, 1, 8, AssetData{AssetID: 2})
+
+	expected := map[uint][]string{
+		tenantWide.ID:       {models.EventAssetFailed, models.EventAssetUploaded, models.EventProcessingCompleted},
+		tenantProcessing.ID: {models.EventProcessingCompleted},
+		owner.ID:            {models.EventAssetUploaded},
+		otherUser.ID:        {models.EventAssetFailed},
+		otherTenant.ID:      nil,
+		inactive.ID:         nil,
+	}
+	for hookID, events := range expected {
+		var got []string
+		for _, delivery := range deliveriesOf(t, hookID) {
+			got = append(got, delivery.Event)
+		}
+		sort.Strings(got)
+		if len(got) != len(events) {
+			t.Errorf("webhook %d recebeu %v, esperado %v", hookID, got, events)
+			continue
+		}
+		for i := range got {
+			if got[i] != events[i] {
+				t.Errorf("webhook %d recebeu %v, esperado %v", hookID, got, events)
+				break
+			}
+		}
+	}
+
+	dispatchDue()
+	if got := len(recv.received()); got != 6 {
+		t.Errorf("%d requisições recebidas, esperado 6", got)
+	}
+}
+
+func TestDeliveryToRemovedOrDisabledWebhookFails(t *testing.T) {
+	setupTestDB(t)
+	recv := newReceiver(t)
+	removed := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: []string{models.EventAssetUploaded}})
+	disabled := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: recv.URL, Events: []string{models.EventAssetUploaded}})
+
+	Emit(models.EventAssetUploaded, 1, 7, AssetData{AssetID: 1})
+	database.DB.Delete(&removed)
+	database.DB.Model(&disabled).Update("active", false)
+	dispatchDue()
+
+	if got := len(recv.received()); got != 0 {
+		t.Fatalf("%d requisições recebidas", got)
+	}
+	for hookID, cause := range map[uint]string{removed.ID: "webhook removido", disabled.ID: "webhook desativado"} {
+		delivery := deliveriesOf(t, hookID)[0]
+		if delivery.Status != models.DeliveryFailed || delivery.Error != cause {
+			t.Errorf("entrega ao webhook %d = %+v", hookID, delivery)
+		}
+	}
+}
+
+func TestDeliveryRefusesPrivateTargets(t *testing.T) {
+	setupTestDB(t)
+	allowPrivateTargets = false
+	recv := newReceiver(t, http.StatusOK)
+	_, port, _ := net.SplitHostPort(recv.Listener.Addr().String())
+
+	// O nome resolve para loopback: a recusa acontece na conexão
+	for _, target := range []string{recv.URL, "http://localhost:" + port} {
+		hook := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: target, Events: []string{models.EventAssetUploaded}})
+		Emit(models.EventAssetUploaded, 1, 7, AssetData{AssetID: 42})
+		dispatchDue()
+
+		deliveries := deliveriesOf(t, hook.ID)
+		if len(deliveries) != 1 {
+			t.Fatalf("%s: %d entregas", target, len(deliveries))
+		}
+		if delivery := deliveries[0]; delivery.Status != models.DeliveryPending || !strings.Contains(delivery.Error, errPrivateTarget.Error()) {
+			t.Errorf("%s: entrega = %s, erro %q", target, delivery.Status, delivery.Error)
+		}
+		database.DB.Delete(&hook)
+	}
+	if n := len(recv.received()); n != 0 {
+		t.Errorf("o receptor em loopback recebeu %d requisições", n)
+	}
+
+	tests := []struct {
+		host    string
+		allowed bool
+	}{
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"localhost", false},
+		{"api.localhost", false},
+		{"10.0.0.5", false},
+		{"172.16.0.1", false},
+		{"192.168.1.10", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::ffff:127.0.0.1", false},
+		{"203.0.113.10", true},
+		{"hooks.example.com", true},
+	}
+	for _, tt := range tests {
+		if got := AllowedHost(tt.host); got != tt.allowed {
+			t.Errorf("AllowedHost(%q) = %t, esperado %t", tt.host, got, tt.allowed)
+		}
+	}
+}
+
+func TestDeliveryDoesNotFollowRedirects(t *testing.T) {
+	setupTestDB(t)
+	internal := newReceiver(t, http.StatusOK)
+	redirect := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	t.Cleanup(redirect.Close)
+
+	hook := createWebhook(t, models.Webhook{TenantID: 1, UserID: 7, URL: redirect.URL, Events: []string{models.EventAssetUploaded}})
+	Emit(models.EventAssetUploaded, 1, 7, AssetData{AssetID: 42})
+	dispatchDue()
+
+	if n := len(internal.received()); n != 0 {
+		t.Errorf("redirecionamento seguido (%d requisições)", n)
+	}
+	deliveries := deliveriesOf(t, hook.ID)
+	if len(deliveries) != 1 || deliveries[0].ResponseStatus != http.StatusFound || deliveries[0].Status != models.DeliveryPending {
+		t.Errorf("entregas = %+v", deliveries)
+	}
+}
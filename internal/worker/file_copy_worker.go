@@ -2,19 +2,23 @@ package worker
 
 import (
 	"encoding/json"
-	"io"
 	"log"
-	"os"
 	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/extractor"
 	"projeto_drm/poc/internal/models"
 	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/scanner"
+	"projeto_drm/poc/internal/storage"
+	"projeto_drm/poc/internal/webhook"
 	"sync"
 	"time"
 )
 
 const assetQueueName = "asset-queue"
 
-// FileCopyWorker is responsible for copying files from temporary storage to the temp directory
+// FileCopyWorker consumes the asset queue. Uploads are streamed straight to the
+// storage backend, so it only runs the asynchronous post-processing of new
+// assets (and copies files for jobs still carrying a temporary file)
 type FileCopyWorker struct {
 	ID   int
 	quit chan bool
@@ -101,67 +105,113 @@ func (w *FileCopyWorker) processJob(job queue.AssetJob) {
 
 	// Get the asset from the database
 	var asset models.Asset
-	if err := database.DB.First(&asset, job.ID).Error; err != nil {
+	if err := database.DB.Where("tenant_id = ?", job.TenantID).First(&asset, job.ID).Error; err != nil {
 		log.Printf("File copy worker %d: Error getting asset %d: %v", w.ID, job.ID, err)
 		return
 	}
 
+	// A newer version was uploaded after this job was enqueued; its own job
+	// takes care of the asset
+	if job.Version != 0 && job.Version != asset.Version {
+		log.Printf("File copy worker %d: Skipping job for asset %d version %d, current version is %d", w.ID, job.ID, job.Version, asset.Version)
+		return
+	}
+
 	// Update asset status to processing
 	asset.Status = models.StatusProcessing
-	if err := database.DB.Save(&asset).Error; err != nil {
+	if err := database.DB.Model(&asset).Where("version = ?", asset.Version).Update("status", asset.Status).Error; err != nil {
 		log.Printf("File copy worker %d: Error updating asset status: %v", w.ID, err)
 		return
 	}
 
-	// Get the temporary file path from the job
-	tempFilePath := job.TempFilePath
-	if tempFilePath == "" {
-		log.Printf("File copy worker %d: No temporary file path provided for asset %d", w.ID, job.ID)
-		updateAssetStatus(&asset, models.StatusFailed)
-		return
+	// Jobs enqueued before uploads were streamed still carry a temporary file
+	// that must be copied to the storage backend
+	if job.TempFilePath != "" {
+		if err := storage.PutFile(queue.Ctx, storage.Default, job.Path, job.TempFilePath, asset.Type); err != nil {
+			log.Printf("File copy worker %d: Error copying file: %v", w.ID, err)
+			updateAssetStatus(&asset, models.StatusFailed)
+			return
+		}
 	}
 
-	// Create the destination file
-	dstPath := job.Path
-	out, err := os.Create(dstPath)
+	// The upload already wrote the file to its final location; post-processing
+	// starts by confirming it is there and complete
+	info, err := storage.Default.Stat(queue.Ctx, job.Path)
 	if err != nil {
-		log.Printf("File copy worker %d: Error creating destination file: %v", w.ID, err)
+		log.Printf("File copy worker %d: Error reading file for asset %d: %v", w.ID, job.ID, err)
+		updateAssetStatus(&asset, models.StatusFailed)
+		return
+	}
+	if info.Size != asset.Size {
+		log.Printf("File copy worker %d: Size mismatch for asset %d: stored %d, expected %d", w.ID, job.ID, info.Size, asset.Size)
 		updateAssetStatus(&asset, models.StatusFailed)
 		return
 	}
-	defer out.Close()
 
-	// Open the source file
-	in, err := os.Open(tempFilePath)
+	// Scan for malware before the asset becomes downloadable
+	result, err := scanAsset(job.Path)
 	if err != nil {
-		log.Printf("File copy worker %d: Error opening source file: %v", w.ID, err)
+		log.Printf("File copy worker %d: Error scanning asset %d: %v", w.ID, job.ID, err)
 		updateAssetStatus(&asset, models.StatusFailed)
 		return
 	}
-	defer in.Close()
+	now := time.Now()
+	asset.ScannedAt = &now
+	if result.Infected {
+		log.Printf("File copy worker %d: Asset %d quarantined: %s", w.ID, job.ID, result.Signature)
+		asset.ScanResult = result.Signature
+		updateAssetStatus(&asset, models.StatusQuarantined)
+		return
+	}
 
-	// Copy the file
-	if _, err = io.Copy(out, in); err != nil {
-		log.Printf("File copy worker %d: Error copying file: %v", w.ID, err)
-		updateAssetStatus(&asset, models.StatusFailed)
+	// Update asset status to completed; a newer version replaced the file if
+	// nothing was updated, and its own job extracts the metadata
+	if !updateAssetStatus(&asset, models.StatusCompleted) {
+		log.Printf("File copy worker %d: Asset %d was replaced by a newer version, skipping", w.ID, job.ID)
 		return
 	}
+	log.Printf("File copy worker %d: Successfully processed asset %d", w.ID, job.ID)
+
+	// Technical metadata is informative only; a failure here does not affect
+	// the asset status
+	if err := extractor.ExtractAsset(queue.Ctx, asset); err != nil {
+		log.Printf("File copy worker %d: Error extracting metadata for asset %d: %v", w.ID, job.ID, err)
+	}
+}
 
-	// Remove the temporary file
-	if err := os.Remove(tempFilePath); err != nil {
-		log.Printf("File copy worker %d: Error removing temporary file: %v", w.ID, err)
-		// Continue anyway, this is not a critical error
+// scanAsset streams the stored file to the configured scanner
+func scanAsset(key string) (scanner.Result, error) {
+	reader, err := storage.Default.Get(queue.Ctx, key)
+	if err != nil {
+		return scanner.Result{}, err
 	}
+	defer reader.Close()
 
-	// Update asset status to completed
-	updateAssetStatus(&asset, models.StatusCompleted)
-	log.Printf("File copy worker %d: Successfully copied file for asset %d", w.ID, job.ID)
+	return scanner.Default.Scan(queue.Ctx, reader)
 }
 
-// updateAssetStatus updates the status of an asset
-func updateAssetStatus(asset *models.Asset, status string) {
+// updateAssetStatus updates the status and scan result of an asset. Only the
+// columns owned by the worker are written, so metadata edited while the job
+// runs is preserved, and nothing is written if a newer version replaced the
+// file in the meantime. Final statuses are notified to webhooks. Returns
+// whether the asset was updated.
+func updateAssetStatus(asset *models.Asset, status string) bool {
 	asset.Status = status
-	if err := database.DB.Save(asset).Error; err != nil {
-		log.Printf("Error updating asset status: %v", err)
+	result := database.DB.Model(asset).Where("version = ?", asset.Version).
+		Select("status", "scan_result", "scanned_at").Updates(asset)
+	if result.Error != nil {
+		log.Printf("Error updating asset status: %v", result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	switch status {
+	case models.StatusCompleted:
+		webhook.EmitAsset(models.EventAssetUploaded, *asset)
+	case models.StatusFailed, models.StatusQuarantined:
+		webhook.EmitAsset(models.EventAssetFailed, *asset)
 	}
+	return true
 }
@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"projeto_drm/poc/internal/database"
+	"projeto_drm/poc/internal/models"
+	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/scanner"
+	"projeto_drm/poc/internal/storage"
+	"strings"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// stubScanner devolve um veredito fixo e guarda o conteúdo recebido; onScan,
+// se definido, roda durante a verificação
+type stubScanner struct {
+	result  scanner.Result
+	err     error
+	scanned string
+	onScan  func()
+}
+
+func (s *stubScanner) Scan(ctx context.Context, r io.Reader) (scanner.Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	s.scanned = string(data)
+	if s.onScan != nil {
+		s.onScan()
+	}
+	return s.result, s.err
+}
+
+// setupScanTest cria um asset pendente com o conteúdo informado e usa stub
+// como scanner padrão
+func setupScanTest(t *testing.T, content string, stub *stubScanner) models.Asset {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("abrir banco: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Asset{}, &models.TechnicalMetadata{}, &models.Webhook{}, &models.WebhookDelivery{}); err != nil {
+		t.Fatalf("migrar banco: %v", err)
+	}
+	database.DB = db
+
+	previousStorage, previousScanner := storage.Default, scanner.Default
+	t.Cleanup(func() { storage.Default, scanner.Default = previousStorage, previousScanner })
+	storage.Default = storage.NewLocal(t.TempDir())
+	scanner.Default = stub
+
+	asset := models.Asset{
+		Name:     "a.txt",
+		Type:     "text/plain",
+		Size:     int64(len(content)),
+		Path:     "blobs/1/a.txt",
+		Status:   models.StatusPending,
+		Version:  1,
+		TenantID: 1,
+		OwnerID:  7,
+	}
+	if err := storage.Default.Put(queue.Ctx, asset.Path, strings.NewReader(content), asset.Size, asset.Type); err != nil {
+		t.Fatalf("gravar arquivo: %v", err)
+	}
+	if err := db.Create(&asset).Error; err != nil {
+		t.Fatalf("criar asset: %v", err)
+	}
+	return asset
+}
+
+func runScanJob(t *testing.T, asset models.Asset) models.Asset {
+	t.Helper()
+
+	worker := &FileCopyWorker{ID: 1}
+	worker.processJob(queue.AssetJob{ID: asset.ID, Path: asset.Path, Type: asset.Type, TenantID: asset.TenantID, Version: asset.Version})
+
+	var reloaded models.Asset
+	if err := database.DB.First(&reloaded, asset.ID).Error; err != nil {
+		t.Fatalf("recarregar asset: %v", err)
+	}
+	return reloaded
+}
+
+func TestProcessJobQuarantinesInfectedAsset(t *testing.T) {
+	stub := &stubScanner{result: scanner.Result{Infected: true, Signature: "Win.Test.EICAR_HDB-1"}}
+	asset := setupScanTest(t, "conteúdo infectado", stub)
+
+	asset = runScanJob(t, asset)
+	if stub.scanned != "conteúdo infectado" {
+		t.Errorf("conteúdo enviado ao scanner = %q", stub.scanned)
+	}
+	if asset.Status != models.StatusQuarantined || asset.ScanResult != "Win.Test.EICAR_HDB-1" || asset.ScannedAt == nil {
+		t.Fatalf("asset = status %q, scan_result %q, scanned_at %v", asset.Status, asset.ScanResult, asset.ScannedAt)
+	}
+}
+
+func TestProcessJobCompletesCleanAsset(t *testing.T) {
+	asset := setupScanTest(t, "conteúdo limpo", &stubScanner{})
+
+	asset = runScanJob(t, asset)
+	if asset.Status != models.StatusCompleted || asset.ScanResult != "" || asset.ScannedAt == nil {
+		t.Fatalf("asset = status %q, scan_result %q, scanned_at %v", asset.Status, asset.ScanResult, asset.ScannedAt)
+	}
+}
+
+func TestProcessJobFailsWhenScanFails(t *testing.T) {
+	asset := setupScanTest(t, "conteúdo", &stubScanner{err: errors.New("clamd: INSTREAM size limit exceeded.")})
+
+	asset = runScanJob(t, asset)
+	if asset.Status != models.StatusFailed || asset.ScannedAt != nil {
+		t.Fatalf("asset = status %q, scanned_at %v", asset.Status, asset.ScannedAt)
+	}
+}
+
+// minimalPDF é um PDF válido de uma página, para a extração de metadados
+const minimalPDF = `%PDF-1.4
+1 0 obj
+<< /Type /Catalog /Pages 2 0 R >>
+endobj
+2 0 obj
+<< /Type /Pages /Kids [3 0 R] /Count 1 >>
+endobj
+3 0 obj
+<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Resources << >> >>
+endobj
+xref
+0 4
+0000000000 65535 f 
+0000000009 00000 n 
+0000000058 00000 n 
+0000000115 00000 n 
+trailer
+<< /Size 4 /Root 1 0 R >>
+startxref
+203
+%%EOF
+`
+
+func TestProcessJobIgnoresVersionReplacedDuringScan(t *testing.T) {
+	stub := &stubScanner{}
+	asset := setupScanTest(t, minimalPDF, stub)
+	asset.Type = "application/pdf"
+	if err := database.DB.Model(&asset).Update("type", asset.Type).Error; err != nil {
+		t.Fatalf("atualizar tipo: %v", err)
+	}
+
+	// Uma nova versão substitui o arquivo enquanto o job antigo é processado
+	stub.onScan = func() {
+		err := database.DB.Model(&models.Asset{}).Where("id = ?", asset.ID).
+			Updates(map[string]interface{}{"version": 2, "status": models.StatusPending}).Error
+		if err != nil {
+			t.Errorf("atualizar versão: %v", err)
+		}
+	}
+
+	reloaded := runScanJob(t, asset)
+	if reloaded.Version != 2 || reloaded.Status != models.StatusPending || reloaded.ScannedAt != nil {
+		t.Fatalf("asset = versão %d, status %q, scanned_at %v", reloaded.Version, reloaded.Status, reloaded.ScannedAt)
+	}
+	var count int64
+	database.DB.Model(&models.TechnicalMetadata{}).Where("asset_id = ?", asset.ID).Count(&count)
+	if count != 0 {
+		t.Errorf("%d metadados técnicos gravados para a versão substituída", count)
+	}
+}
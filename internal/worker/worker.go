@@ -1,43 +1,75 @@
 package worker
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"mime"
 	"os"
+	"path"
 	"path/filepath"
 	"projeto_drm/poc/internal/database"
 	"projeto_drm/poc/internal/models"
 	"projeto_drm/poc/internal/queue"
+	"projeto_drm/poc/internal/storage"
 	"projeto_drm/poc/internal/watermarker"
+	"projeto_drm/poc/internal/webhook"
+	"strconv"
 	"sync"
 	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// reclaimInterval is how often the pool returns jobs whose lease expired
+	// (crashed process, killed ffmpeg) to the processing queue
+	reclaimInterval = 30 * time.Second
+	// promoteInterval is how often jobs waiting for a retry are checked
+	promoteInterval = time.Second
+
+	// Job progress once the original is downloaded and once the watermarked
+	// copy is ready; uploading it to the cache takes the rest
+	progressFetched     = 5.0
+	progressWatermarked = 95.0
 )
 
 type Worker struct {
 	ID    int
 	queue *queue.RedisQueue
 	quit  chan bool
+	// consumer identifies the worker's in-flight list; it must be unique
+	// across every process consuming the queue
+	consumer string
 }
 
 type WorkerPool struct {
-	workers []*Worker
-	queue   *queue.RedisQueue
-	wg      sync.WaitGroup
+	workers     []*Worker
+	queue       *queue.RedisQueue
+	wg          sync.WaitGroup
+	stopReclaim chan bool
 }
 
 func NewWorkerPool(size int, redisQueue *queue.RedisQueue) *WorkerPool {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "worker"
+	}
+
 	workers := make([]*Worker, size)
 	for i := 0; i < size; i++ {
 		workers[i] = &Worker{
-			ID:    i + 1,
-			queue: redisQueue,
-			quit:  make(chan bool),
+			ID:       i + 1,
+			queue:    redisQueue,
+			quit:     make(chan bool),
+			consumer: fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), i+1),
 		}
 	}
 
 	return &WorkerPool{
-		workers: workers,
-		queue:   redisQueue,
+		workers:     workers,
+		queue:       redisQueue,
+		stopReclaim: make(chan bool),
 	}
 }
 
@@ -48,6 +80,9 @@ func (wp *WorkerPool) Start() {
 		wp.wg.Add(1)
 		go worker.Start(&wp.wg)
 	}
+
+	wp.wg.Add(1)
+	go wp.maintain()
 }
 
 func (wp *WorkerPool) Stop() {
@@ -56,11 +91,46 @@ func (wp *WorkerPool) Stop() {
 	for _, worker := range wp.workers {
 		worker.quit <- true
 	}
+	wp.stopReclaim <- true
 
 	wp.wg.Wait()
 	log.Println("Worker pool stopped")
 }
 
+// maintain periodically requeues abandoned jobs, including the ones left
+// behind by a previous run of this process, and jobs whose retry is due
+func (wp *WorkerPool) maintain() {
+	defer wp.wg.Done()
+
+	reclaimTicker := time.NewTicker(reclaimInterval)
+	defer reclaimTicker.Stop()
+	promoteTicker := time.NewTicker(promoteInterval)
+	defer promoteTicker.Stop()
+
+	wp.reclaim()
+	for {
+		select {
+		case <-wp.stopReclaim:
+			return
+		case <-reclaimTicker.C:
+			wp.reclaim()
+		case <-promoteTicker.C:
+			if _, err := wp.queue.PromoteDue(); err != nil {
+				log.Printf("Error requeueing delayed jobs: %v", err)
+			}
+		}
+	}
+}
+
+func (wp *WorkerPool) reclaim() {
+	reclaimed, err := wp.queue.ReclaimExpired()
+	if err != nil {
+		log.Printf("Error reclaiming expired jobs: %v", err)
+	} else if reclaimed > 0 {
+		log.Printf("Requeued %d abandoned jobs", reclaimed)
+	}
+}
+
 func (w *Worker) Start(wg *sync.WaitGroup) {
 	defer wg.Done()
 	log.Printf("Worker %d started", w.ID)
@@ -71,71 +141,188 @@ func (w *Worker) Start(wg *sync.WaitGroup) {
 			log.Printf("Worker %d stopping", w.ID)
 			return
 		default:
-			job, err := w.queue.DequeueJob()
+			delivery, err := w.queue.DequeueJob(w.consumer)
+			if errors.Is(err, queue.ErrNoJob) {
+				continue
+			}
 			if err != nil {
 				log.Printf("Worker %d: Error dequeueing job: %v", w.ID, err)
+				// Any other error leaves the job in flight to be reclaimed
+				if errors.Is(err, queue.ErrInvalidJob) {
+					w.ack(delivery)
+				}
 				time.Sleep(time.Second)
 				continue
 			}
 
-			w.processJob(job)
+			w.handle(delivery)
 		}
 	}
 }
 
-func (w *Worker) processJob(job *queue.ProcessingJob) {
-	log.Printf("Worker %d: Processing job %s for user %s", w.ID, job.ID, job.UserID)
+// handle processes the delivery while renewing its lease, then acks it or,
+// on failure, schedules a retry or dead-letters it
+func (w *Worker) handle(delivery *queue.Delivery) {
+	// Reclaimed jobs count the interrupted attempt; a job that keeps crashing
+	// the process is not run again once it has used all of them
+	if job := delivery.Job; job.Attempts >= w.queue.MaxAttempts {
+		log.Printf("Worker %d: Job %s was interrupted after %d attempts, moving to dead-letter queue",
+			w.ID, job.ID, job.Attempts)
+		w.deadLetter(delivery, errors.New(job.LastError), false)
+		return
+	}
+
+	done := make(chan struct{})
+	go w.heartbeat(delivery, done)
+
+	err := w.processJob(delivery.Job)
+
+	close(done)
+	if err != nil {
+		w.fail(delivery, err)
+		return
+	}
+	w.ack(delivery)
+}
+
+// fail retries transient errors with exponential backoff until MaxAttempts is
+// reached; permanent errors and exhausted jobs go to the dead-letter queue
+func (w *Worker) fail(delivery *queue.Delivery, cause error) {
+	job := delivery.Job
+	job.Attempts++
+	job.LastError = cause.Error()
+
+	permanent := isPermanent(cause)
+	if permanent || job.Attempts >= w.queue.MaxAttempts {
+		log.Printf("Worker %d: Job %s failed after %d attempts (permanent: %t), moving to dead-letter queue",
+			w.ID, job.ID, job.Attempts, permanent)
+		w.deadLetter(delivery, cause, permanent)
+		return
+	}
+
+	delay := w.queue.RetryDelay(job.Attempts)
+	log.Printf("Worker %d: Job %s failed (attempt %d of %d), retrying in %s",
+		w.ID, job.ID, job.Attempts, w.queue.MaxAttempts, delay)
+	// The download handler keeps answering 202 while the job is queued
+	w.updateJobStatus(job, "queued", fmt.Sprintf("tentativa %d de %d falhou: %v", job.Attempts, w.queue.MaxAttempts, cause))
+	w.queue.SetJobAttempt(job.ID, job.Attempts, cause.Error(), time.Now().Add(delay))
+	if err := w.queue.Retry(delivery, delay); err != nil {
+		log.Printf("Worker %d: Error scheduling retry of job %s: %v", w.ID, job.ID, err)
+	}
+}
+
+// deadLetter marks the job as failed and moves it to the dead-letter queue
+func (w *Worker) deadLetter(delivery *queue.Delivery, cause error, permanent bool) {
+	job := delivery.Job
+	w.updateJobStatus(job, "failed", cause.Error())
+	w.queue.SetJobAttempt(job.ID, job.Attempts, cause.Error(), time.Time{})
+	if err := w.queue.DeadLetter(delivery, cause, permanent); err != nil {
+		log.Printf("Worker %d: Error dead-lettering job %s: %v", w.ID, job.ID, err)
+	}
+}
+
+// heartbeat keeps the job leased while it is being processed
+func (w *Worker) heartbeat(delivery *queue.Delivery, done <-chan struct{}) {
+	ticker := time.NewTicker(w.queue.VisibilityTimeout / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			leased, err := w.queue.Extend(delivery)
+			if err != nil {
+				log.Printf("Worker %d: Error extending lease of job %s: %v", w.ID, delivery.Job.ID, err)
+			} else if !leased {
+				log.Printf("Worker %d: Lease of job %s expired, it was requeued", w.ID, delivery.Job.ID)
+				return
+			}
+		}
+	}
+}
+
+func (w *Worker) ack(delivery *queue.Delivery) {
+	if err := w.queue.Ack(delivery); err != nil {
+		log.Printf("Worker %d: Error acknowledging job: %v", w.ID, err)
+	}
+}
+
+func (w *Worker) processJob(job *queue.ProcessingJob) error {
+	log.Printf("Worker %d: Processing job %s for user %s (attempt %d)", w.ID, job.ID, job.UserID, job.Attempts+1)
+
+	// O registro some quando o asset é expurgado ou ganha uma nova versão
+	var processedAsset models.ProcessedAsset
+	err := findProcessedAsset(job).Limit(1).Find(&processedAsset).Error
+	if err != nil {
+		return err
+	}
+	if processedAsset.ID == 0 {
+		log.Printf("Worker %d: Job %s is obsolete, skipping", w.ID, job.ID)
+		return nil
+	}
 
 	// Atualizar status para "processing"
-	w.updateJobStatus(job.ID, job.AssetID, job.UserID, "processing", "")
+	w.updateJobStatus(job, "processing", "")
+	w.queue.SetJobAttempt(job.ID, job.Attempts+1, job.LastError, time.Time{})
 
 	// Processar arquivo
-	err := w.processFile(job)
+	err = w.processFile(job)
 	if err != nil {
 		log.Printf("Worker %d: Error processing job %s: %v", w.ID, job.ID, err)
-		w.updateJobStatus(job.ID, job.AssetID, job.UserID, "failed", err.Error())
-		return
+		return err
 	}
 
 	// Sucesso
-	w.updateJobStatus(job.ID, job.AssetID, job.UserID, "completed", "")
+	w.updateJobStatus(job, "completed", "")
 	log.Printf("Worker %d: Job %s completed successfully", w.ID, job.ID)
+	return nil
 }
 
 func (w *Worker) processFile(job *queue.ProcessingJob) error {
-	// Criar diretório de cache se não existir
-	cacheDir := "cache"
-	if err := os.MkdirAll(cacheDir, os.ModePerm); err != nil {
-		return fmt.Errorf("erro ao criar diretório de cache: %v", err)
-	}
-
-	// Gerar caminho do cache
+	// Gerar chave do cache no prefixo do tenant. O ID do asset entra na chave
+	// porque assets deduplicados compartilham o mesmo arquivo original, e a
+	// versão porque uma versão pode voltar ao conteúdo de outra.
 	log.Printf("Gerando asset path %s\n", job.AssetPath)
-	filename := filepath.Base(job.AssetPath)
-	cachePath := filepath.Join(cacheDir, fmt.Sprintf("%s_%s", job.UserID, filename))
+	filename := path.Base(job.AssetPath)
+	cachePath := path.Join("cache", fmt.Sprintf("%d", job.TenantID),
+		fmt.Sprintf("%s_%s_v%d_%s", job.UserID, job.AssetID, jobAssetVersion(job), filename))
+
+	// ffmpeg e pdfcpu trabalham com arquivos locais
+	inputPath, cleanup, err := storage.FetchToFile(queue.Ctx, storage.Default, job.AssetPath)
+	if errors.Is(err, storage.ErrNotFound) {
+		return permanentError(fmt.Errorf("arquivo original não encontrado: %v", err))
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao obter arquivo original: %v", err)
+	}
+	defer cleanup()
 
-	//pra fins de debug, verifica quais os arquivos existem dentro de temp/
-	files, errr := os.ReadDir(cacheDir)
-	if errr != nil {
-		return fmt.Errorf("erro ao ler diretório de cache: %v", errr)
+	// A marca d'água ocupa o intervalo entre progressFetched e progressWatermarked
+	report := w.progressReporter(job)
+	report(progressFetched)
+	watermarkProgress := func(percent float64) {
+		report(progressFetched + percent*(progressWatermarked-progressFetched)/100)
 	}
 
-	for _, file := range files {
-		log.Printf("Arquivo no cache: %s", file.Name())
+	outputFile, err := os.CreateTemp("", "watermark-*"+path.Ext(filename))
+	if err != nil {
+		return fmt.Errorf("erro ao criar arquivo temporário: %v", err)
 	}
-	/// debug
+	outputPath := outputFile.Name()
+	outputFile.Close()
+	defer os.Remove(outputPath)
 
 	// Aplicar watermark baseado no tipo
 	watermarkText := fmt.Sprintf("%s (%s)", job.UserID, job.UserEmail)
 	ext := filepath.Ext(job.AssetPath)
 
-	var err error
 	switch ext {
 	case ".pdf":
-		err = watermarker.AddPDFWatermark(job.AssetPath, cachePath, watermarkText)
+		err = watermarker.AddPDFWatermark(inputPath, outputPath, watermarkText, watermarkProgress)
 	case ".mp4", ".mov":
 		// Verificar tamanho do arquivo para escolher estratégia
-		fileInfo, statErr := os.Stat(job.AssetPath)
+		fileInfo, statErr := os.Stat(inputPath)
 		if statErr != nil {
 			return fmt.Errorf("erro ao verificar arquivo: %v", statErr)
 		}
@@ -144,21 +331,29 @@ func (w *Worker) processFile(job *queue.ProcessingJob) error {
 		if fileInfo.Size() > 500*1024*1024 {
 			log.Printf("Arquivo grande detectado (%.2f MB), usando processamento otimizado",
 				float64(fileInfo.Size())/(1024*1024))
-			err = watermarker.AddVideoWatermarkLarge(job.AssetPath, cachePath, watermarkText)
+			err = watermarker.AddVideoWatermarkLarge(inputPath, outputPath, watermarkText, watermarkProgress)
 		} else {
-			err = watermarker.AddVideoWatermark(job.AssetPath, cachePath, watermarkText)
+			err = watermarker.AddVideoWatermark(inputPath, outputPath, watermarkText, watermarkProgress)
 		}
 	default:
-		return fmt.Errorf("tipo de arquivo não suportado: %s", ext)
+		return permanentError(fmt.Errorf("tipo de arquivo não suportado: %s", ext))
 	}
 
 	if err != nil {
 		return fmt.Errorf("erro ao aplicar watermark: %v", err)
 	}
 
+	if err := storage.PutFile(queue.Ctx, storage.Default, cachePath, outputPath, mime.TypeByExtension(ext)); err != nil {
+		return fmt.Errorf("erro ao salvar arquivo no cache: %v", err)
+	}
+
 	// Atualizar cache path no banco
 	var processedAsset models.ProcessedAsset
-	if err := database.DB.Where("asset_id = ? AND user_id = ?", job.AssetID, job.UserID).First(&processedAsset).Error; err != nil {
+	err = findProcessedAsset(job).First(&processedAsset).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return permanentError(errors.New("processed asset removido durante o processamento"))
+	}
+	if err != nil {
 		return fmt.Errorf("erro ao encontrar processed asset: %v", err)
 	}
 
@@ -169,13 +364,40 @@ func (w *Worker) processFile(job *queue.ProcessingJob) error {
 	return database.DB.Save(&processedAsset).Error
 }
 
-func (w *Worker) updateJobStatus(jobID, assetID, userID, status, errorMsg string) {
+// progressReporter publishes the job progress, skipping changes below one
+// percentage point so ffmpeg output does not flood Redis
+func (w *Worker) progressReporter(job *queue.ProcessingJob) func(percent float64) {
+	last := -1.0
+	return func(percent float64) {
+		if percent-last < 1 {
+			return
+		}
+		last = percent
+		if err := w.queue.SetJobProgress(job.ID, percent); err != nil {
+			log.Printf("Worker %d: Error updating progress of job %s: %v", w.ID, job.ID, err)
+		}
+
+		event := queue.NewJobEvent(job, "progress")
+		event.Progress = percent
+		w.publish(event)
+	}
+}
+
+// publish notifies clients following the asset through GET /assets/:id/events
+func (w *Worker) publish(event queue.JobEvent) {
+	if err := w.queue.PublishJobEvent(event); err != nil {
+		log.Printf("Worker %d: Error publishing %s event of job %s: %v", w.ID, event.Type, event.JobID, err)
+	}
+}
+
+func (w *Worker) updateJobStatus(job *queue.ProcessingJob, status, errorMsg string) {
 	// Atualizar status no Redis
-	w.queue.SetJobStatus(jobID, status)
+	w.queue.SetJobStatus(job.ID, status)
 
 	// Atualizar status no banco
 	var processedAsset models.ProcessedAsset
-	if err := database.DB.Where("asset_id = ? AND user_id = ?", assetID, userID).First(&processedAsset).Error; err != nil {
+	err := findProcessedAsset(job).First(&processedAsset).Error
+	if err != nil {
 		log.Printf("Error finding processed asset: %v", err)
 		return
 	}
@@ -185,5 +407,75 @@ func (w *Worker) updateJobStatus(jobID, assetID, userID, status, errorMsg string
 
 	if err := database.DB.Save(&processedAsset).Error; err != nil {
 		log.Printf("Error updating processed asset status: %v", err)
+		return
+	}
+
+	// Publicado depois do banco para o cliente encontrar a cópia ao baixar
+	event := queue.NewJobEvent(job, status)
+	event.Error = errorMsg
+	if status == "completed" {
+		event.Progress = 100
+	}
+	w.publish(event)
+	switch status {
+	case "completed":
+		emitProcessing(models.EventProcessingCompleted, job, "")
+	case "failed":
+		emitProcessing(models.EventProcessingFailed, job, errorMsg)
+	}
+}
+
+// emitProcessing notifies webhooks of the user who requested the download
+// and of the tenant
+func emitProcessing(eventType string, job *queue.ProcessingJob, errorMsg string) {
+	userID, _ := strconv.ParseUint(job.UserID, 10, 64)
+	data := webhook.ProcessingData{
+		JobID:        job.ID,
+		AssetID:      job.AssetID,
+		AssetVersion: jobAssetVersion(job),
+		UserID:       job.UserID,
+		Attempts:     job.Attempts,
+		Error:        errorMsg,
+	}
+	if eventType == models.EventProcessingCompleted {
+		// job.Attempts only counts the failed attempts
+		data.Attempts++
+		data.DownloadURL = fmt.Sprintf("/assets/%s/download?version=%d", job.AssetID, jobAssetVersion(job))
+	}
+	webhook.Emit(eventType, job.TenantID, uint(userID), data)
+}
+
+// permanentFailure marks errors that would happen again on every attempt,
+// such as a missing original or an unsupported type, so the job is not retried
+type permanentFailure struct {
+	err error
+}
+
+func (p permanentFailure) Error() string { return p.err.Error() }
+
+func (p permanentFailure) Unwrap() error { return p.err }
+
+func permanentError(err error) error {
+	return permanentFailure{err: err}
+}
+
+func isPermanent(err error) bool {
+	var p permanentFailure
+	return errors.As(err, &p)
+}
+
+// findProcessedAsset selects the cache entry of the job. The version is part
+// of the lookup so a job for a replaced version never updates the entry of
+// the current one.
+func findProcessedAsset(job *queue.ProcessingJob) *gorm.DB {
+	return database.DB.Where("asset_id = ? AND user_id = ? AND tenant_id = ? AND asset_version = ?",
+		job.AssetID, job.UserID, job.TenantID, jobAssetVersion(job))
+}
+
+// jobAssetVersion treats jobs enqueued before versioning as version 1
+func jobAssetVersion(job *queue.ProcessingJob) int {
+	if job.AssetVersion == 0 {
+		return 1
 	}
+	return job.AssetVersion
 }